@@ -0,0 +1,112 @@
+package nbt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Typed access to nested values, without an ok check at every level.
+
+// AccessError describes a failure to find a value of the expected type at
+// a given path. Path holds the components successfully followed; if the
+// failure was in following another component, Component is that
+// component. Otherwise the value was found, but wasn't of type Want.
+type AccessError struct {
+	Path      []interface{}
+	Component interface{}
+	Want, Got Type
+	Missing   bool
+}
+
+func formatAccessPath(path []interface{}) string {
+	buf := &strings.Builder{}
+	for _, comp := range path {
+		switch c := comp.(type) {
+		case int, Int:
+			fmt.Fprintf(buf, "[%d]", c)
+		default:
+			if buf.Len() > 0 {
+				buf.WriteByte('.')
+			}
+			fmt.Fprintf(buf, "%s", c)
+		}
+	}
+	if buf.Len() == 0 {
+		return "root"
+	}
+	return buf.String()
+}
+
+func (e *AccessError) Error() string {
+	at := formatAccessPath(e.Path)
+	switch {
+	case e.Missing:
+		return fmt.Sprintf("at %s: no entry %v", at, e.Component)
+	case e.Component != nil:
+		return fmt.Sprintf("at %s: %v can't be indexed by %T %v", at, e.Got, e.Component, e.Component)
+	default:
+		return fmt.Sprintf("at %s: expected %v, got %v", at, e.Want, e.Got)
+	}
+}
+
+// Get follows path from t, and yields the value found there, which must be
+// of type T. Path components are strings (or Strings) for Compound entries,
+// and ints (or Ints) for List and array elements. So, for instance:
+//
+//	count, err := nbt.Get[nbt.Byte](root, "Inventory", 0, "Count")
+func Get[T Tag](t Tag, path ...interface{}) (T, error) {
+	var zero T
+	cur := t
+	for i, comp := range path {
+		next, ok := TagElement(cur, comp)
+		if !ok {
+			e := &AccessError{Path: path[:i], Component: comp}
+			if cur != nil {
+				e.Got = cur.Type()
+			}
+			switch comp.(type) {
+			case string, String:
+				e.Missing = e.Got == TypeCompound
+			case int, Int:
+				e.Missing = tagIsIndexable(cur)
+			}
+			return zero, e
+		}
+		if lz, ok := next.(*lazyTag); ok {
+			// TagElement only leaves these when they can't be decoded
+			_, err := lz.decode()
+			return zero, fmt.Errorf("at %s: %w", formatAccessPath(path[:i+1]), err)
+		}
+		cur = next
+	}
+	out, ok := cur.(T)
+	if !ok {
+		e := &AccessError{Path: path}
+		if cur != nil {
+			e.Got = cur.Type()
+		}
+		// if T is an interface type, such as Tag, the zero value is
+		// nil and has no Type, but then any non-nil value would have
+		// matched.
+		if Tag(zero) != nil {
+			e.Want = zero.Type()
+		}
+		return zero, e
+	}
+	return out, nil
+}
+
+// GetOr is like Get, but yields def instead of an error.
+func GetOr[T Tag](t Tag, def T, path ...interface{}) T {
+	out, err := Get[T](t, path...)
+	if err != nil {
+		return def
+	}
+	return out
+}
+
+// Str yields the String stored under key in c. It's not called String
+// because that's the Stringer method.
+func (c Compound) Str(key String) (String, error) {
+	return Get[String](c, key)
+}
@@ -0,0 +1,207 @@
+package nbt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"unsafe"
+)
+
+// Encoding into byte slices. Writing a tag a few bytes at a time is slow
+// on unbuffered writers, so StoreTag and the container types' Store
+// methods build their output in a pooled buffer, and write it all at
+// once.
+
+// AppendTag appends t, stored under the given name as StoreTag would
+// store it, to dst, and yields the extended slice. On error, the
+// returned slice is dst, unchanged.
+func AppendTag(dst []byte, t Tag, name String) ([]byte, error) {
+	out, err := appendTag(dst, t, name)
+	if err != nil {
+		return dst, err
+	}
+	return out, nil
+}
+
+// appendTag appends a header and payload. On error, the slice it yields
+// may have partial output appended.
+func appendTag(dst []byte, t Tag, name String) ([]byte, error) {
+	// TypeEnd doesn't get its name written.
+	if t.Type() == TypeEnd {
+		return append(dst, 0), nil
+	}
+	dst = append(dst, byte(t.Type()))
+	dst, err := appendString(dst, name)
+	if err != nil {
+		return dst, err
+	}
+	return appendPayload(dst, t)
+}
+
+// appendString appends a String payload.
+func appendString(dst []byte, s String) ([]byte, error) {
+	if len(s) > 32767 {
+		return dst, fmt.Errorf("can't store %d-byte string", len(s))
+	}
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(s)))
+	return append(dst, s...), nil
+}
+
+// appendPayload appends a payload. On error, the slice it yields may have
+// partial output appended.
+func appendPayload(dst []byte, t Tag) ([]byte, error) {
+	var err error
+	switch x := t.(type) {
+	case End:
+	case Byte:
+		dst = append(dst, byte(x))
+	case Short:
+		dst = binary.BigEndian.AppendUint16(dst, uint16(x))
+	case Int:
+		dst = binary.BigEndian.AppendUint32(dst, uint32(x))
+	case Long:
+		dst = binary.BigEndian.AppendUint64(dst, uint64(x))
+	case Float:
+		dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(float32(x)))
+	case Double:
+		dst = binary.BigEndian.AppendUint64(dst, math.Float64bits(float64(x)))
+	case String:
+		dst, err = appendString(dst, x)
+	case ByteArray:
+		dst = binary.BigEndian.AppendUint32(dst, uint32(len(x)))
+		dst = append(dst, *(*[]byte)(unsafe.Pointer(&x))...)
+	case IntArray:
+		dst = binary.BigEndian.AppendUint32(dst, uint32(len(x)))
+		for _, v := range x {
+			dst = binary.BigEndian.AppendUint32(dst, uint32(v))
+		}
+	case LongArray:
+		dst = binary.BigEndian.AppendUint32(dst, uint32(len(x)))
+		for _, v := range x {
+			dst = binary.BigEndian.AppendUint64(dst, uint64(v))
+		}
+	case List:
+		dst = append(dst, byte(x.Contents))
+		dst = binary.BigEndian.AppendUint32(dst, uint32(x.Length()))
+		if x.data != nil {
+			dst, err = x.data.appendData(dst)
+		}
+	case Compound:
+		for k, v := range x {
+			if dst, err = appendTag(dst, v, k); err != nil {
+				return dst, err
+			}
+		}
+		dst = append(dst, 0)
+	case *lazyTag:
+		if v := x.decoded(); v != nil {
+			return appendPayload(dst, v)
+		}
+		dst = append(dst, x.raw...)
+	default:
+		// custom types can only store themselves
+		aw := appendWriter(dst)
+		err = t.Store(&aw)
+		dst = aw
+	}
+	return dst, err
+}
+
+// StoreSorted writes the payload of t, as t.Store would, but with the
+// entries of compounds, at any depth, in sorted order of their keys, so
+// the same tree always produces the same output. Lazily loaded entries
+// are decoded to sort them.
+func StoreSorted(w io.Writer, t Tag) error {
+	return storeWith(w, func(dst []byte) ([]byte, error) {
+		return appendSorted(dst, t)
+	})
+}
+
+// appendSorted appends a payload, with compound entries in sorted order.
+func appendSorted(dst []byte, t Tag) ([]byte, error) {
+	var err error
+	switch x := t.(type) {
+	case Compound:
+		for _, k := range sortedKeys(x) {
+			v, _ := x.entry(k)
+			if v.Type() == TypeEnd {
+				// as in appendTag
+				dst = append(dst, 0)
+				continue
+			}
+			dst = append(dst, byte(v.Type()))
+			if dst, err = appendString(dst, k); err != nil {
+				return dst, err
+			}
+			if dst, err = appendSorted(dst, v); err != nil {
+				return dst, err
+			}
+		}
+		return append(dst, 0), nil
+	case List:
+		if x.Contents != TypeCompound && x.Contents != TypeList {
+			// nothing to sort
+			return appendPayload(dst, x)
+		}
+		dst = append(dst, byte(x.Contents))
+		dst = binary.BigEndian.AppendUint32(dst, uint32(x.Length()))
+		mixed := x.Mixed()
+		err = x.Iterate(func(_ int, v Tag) error {
+			if mixed {
+				v = wrapElement(v)
+			}
+			dst, err = appendSorted(dst, v)
+			return err
+		})
+		return dst, err
+	case *lazyTag:
+		if v, err := x.decode(); err == nil {
+			return appendSorted(dst, v)
+		}
+	}
+	return appendPayload(dst, t)
+}
+
+// appendWriter is an io.Writer which appends to a byte slice.
+type appendWriter []byte
+
+func (aw *appendWriter) Write(p []byte) (int, error) {
+	*aw = append(*aw, p...)
+	return len(p), nil
+}
+
+// storeBuffers holds buffers for StoreTag and storePayload.
+var storeBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 4096)
+		return &buf
+	},
+}
+
+// maxPooledBuffer is the largest buffer which will be kept for reuse, so
+// that storing one huge tree doesn't pin the memory forever.
+const maxPooledBuffer = 1 << 20
+
+// storeWith builds output in a pooled buffer using fn, then writes it
+// to w in a single call.
+func storeWith(w io.Writer, fn func([]byte) ([]byte, error)) error {
+	bufp := storeBuffers.Get().(*[]byte)
+	buf, err := fn((*bufp)[:0])
+	if err == nil {
+		_, err = w.Write(buf)
+	}
+	if cap(buf) <= maxPooledBuffer {
+		*bufp = buf
+		storeBuffers.Put(bufp)
+	}
+	return err
+}
+
+// storePayload writes the payload of t to w in a single call.
+func storePayload(w io.Writer, t Tag) error {
+	return storeWith(w, func(dst []byte) ([]byte, error) {
+		return appendPayload(dst, t)
+	})
+}
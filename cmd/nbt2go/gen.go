@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/seebs/nbt"
+)
+
+// shape is the structure inferred for a node from every sample of it.
+type shape struct {
+	typ      nbt.Type
+	seen     bool
+	conflict bool
+	// elem is the shape of a list's elements
+	elem *shape
+	// fields, and how many compounds have been merged, for compounds
+	fields map[nbt.String]*field
+	count  int
+	// name is the Go type name, for compounds
+	name string
+}
+
+// field is a compound entry, and how many of the compounds had it.
+type field struct {
+	shape *shape
+	seen  int
+}
+
+// integerRank orders the integer types by size, so that a field which is
+// sometimes an Int and sometimes a Long can be an int64.
+var integerRank = map[nbt.Type]int{
+	nbt.TypeByte:  1,
+	nbt.TypeShort: 2,
+	nbt.TypeInt:   3,
+	nbt.TypeLong:  4,
+}
+
+// merge adds the structure of t to s.
+func (s *shape) merge(t nbt.Tag) {
+	typ := t.Type()
+	switch {
+	case !s.seen:
+		s.typ, s.seen = typ, true
+	case s.typ == typ:
+	case integerRank[s.typ] != 0 && integerRank[typ] != 0:
+		if integerRank[typ] > integerRank[s.typ] {
+			s.typ = typ
+		}
+	default:
+		s.conflict = true
+	}
+	if s.conflict {
+		return
+	}
+	switch x := t.(type) {
+	case nbt.Compound:
+		if s.fields == nil {
+			s.fields = make(map[nbt.String]*field)
+		}
+		s.count++
+		for k, v := range x.Sorted() {
+			f := s.fields[k]
+			if f == nil {
+				f = &field{shape: &shape{}}
+				s.fields[k] = f
+			}
+			f.seen++
+			f.shape.merge(v)
+		}
+	case nbt.List:
+		if s.elem == nil {
+			s.elem = &shape{}
+		}
+		if x.Mixed() {
+			s.elem.conflict = true
+			return
+		}
+		for _, e := range x.All() {
+			s.elem.merge(e)
+		}
+	}
+}
+
+// sortedFields yields the keys of s's fields in order.
+func (s *shape) sortedFields() []nbt.String {
+	keys := make([]nbt.String, 0, len(s.fields))
+	for k := range s.fields {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// generator assigns names to struct types, and writes them out.
+type generator struct {
+	used    map[string]bool
+	structs []*shape
+}
+
+func newGenerator() *generator {
+	return &generator{used: make(map[string]bool)}
+}
+
+// identifier converts an NBT key to an exported Go identifier.
+func identifier(key string) string {
+	buf := &strings.Builder{}
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+	out := buf.String()
+	if out == "" || unicode.IsDigit(rune(out[0])) {
+		out = "X" + out
+	}
+	return out
+}
+
+// name assigns Go type names to every compound in s, using want if it's
+// available, and otherwise qualifying it with parent.
+func (g *generator) name(s *shape, want, parent string) {
+	if s.conflict {
+		return
+	}
+	if s.elem != nil {
+		g.name(s.elem, want+"Elem", parent)
+		return
+	}
+	if s.typ != nbt.TypeCompound {
+		return
+	}
+	name := want
+	if g.used[name] {
+		name = parent + want
+	}
+	for i := 2; g.used[name]; i++ {
+		name = fmt.Sprintf("%s%s%d", parent, want, i)
+	}
+	g.used[name] = true
+	s.name = name
+	g.structs = append(g.structs, s)
+	for _, k := range s.sortedFields() {
+		g.name(s.fields[k].shape, identifier(string(k)), name)
+	}
+}
+
+// goType yields the Go type for values of shape s.
+func goType(s *shape) string {
+	if s.conflict || !s.seen {
+		return "nbt.Tag"
+	}
+	switch s.typ {
+	case nbt.TypeByte:
+		return "int8"
+	case nbt.TypeShort:
+		return "int16"
+	case nbt.TypeInt:
+		return "int32"
+	case nbt.TypeLong:
+		return "int64"
+	case nbt.TypeFloat:
+		return "float32"
+	case nbt.TypeDouble:
+		return "float64"
+	case nbt.TypeString:
+		return "string"
+	case nbt.TypeByteArray:
+		return "[]int8"
+	case nbt.TypeIntArray:
+		return "[]int32"
+	case nbt.TypeLongArray:
+		return "[]int64"
+	case nbt.TypeList:
+		return "[]" + goType(s.elem)
+	case nbt.TypeCompound:
+		return s.name
+	}
+	return "nbt.Tag"
+}
+
+// isIntegerList indicates whether s is a list which would have the same Go
+// type as an array, so it needs to be marked as a list in its tag.
+func isIntegerList(s *shape) bool {
+	if s.conflict || s.typ != nbt.TypeList || s.elem.conflict {
+		return false
+	}
+	switch s.elem.typ {
+	case nbt.TypeByte, nbt.TypeInt, nbt.TypeLong:
+		return s.elem.seen
+	}
+	return false
+}
+
+// usesNBT indicates whether any field of the structs needs the nbt
+// package.
+func (g *generator) usesNBT() bool {
+	for _, s := range g.structs {
+		for _, f := range s.fields {
+			if strings.Contains(goType(f.shape), "nbt.") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// generateTypes yields formatted source for the types of root, which
+// is a compound shape, naming the top-level type typeName.
+func generateTypes(root *shape, pkg, typeName string, sources []string) ([]byte, error) {
+	g := newGenerator()
+	g.name(root, typeName, "")
+	return format.Source(g.generate(pkg, sources))
+}
+
+// generate writes the struct types out as Go source, not yet formatted.
+func (g *generator) generate(pkg string, files []string) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by nbt2go from %s; DO NOT EDIT.\n\n", strings.Join(files, ", "))
+	fmt.Fprintf(buf, "package %s\n\n", pkg)
+	if g.usesNBT() {
+		fmt.Fprintf(buf, "import \"github.com/seebs/nbt\"\n\n")
+	}
+	for _, s := range g.structs {
+		fmt.Fprintf(buf, "type %s struct {\n", s.name)
+		names := make(map[string]bool)
+		for _, k := range s.sortedFields() {
+			f := s.fields[k]
+			name := identifier(string(k))
+			for i := 2; names[name]; i++ {
+				name = fmt.Sprintf("%s%d", identifier(string(k)), i)
+			}
+			names[name] = true
+			typ := goType(f.shape)
+			tag := string(k)
+			if isIntegerList(f.shape) {
+				tag += ",list"
+			}
+			if f.seen < s.count {
+				// optional: pointers for things which don't have a
+				// usable nil value
+				if !strings.HasPrefix(typ, "[]") && typ != "nbt.Tag" {
+					typ = "*" + typ
+				}
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(buf, "\t%s %s `nbt:%q`\n", name, typ, tag)
+		}
+		fmt.Fprintf(buf, "}\n\n")
+	}
+	return buf.Bytes()
+}
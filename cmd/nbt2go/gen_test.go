@@ -0,0 +1,112 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/seebs/nbt"
+)
+
+// fakeNBT is enough of the nbt package to type-check generated code.
+func fakeNBT() *types.Package {
+	pkg := types.NewPackage("github.com/seebs/nbt", "nbt")
+	tag := types.NewTypeName(token.NoPos, pkg, "Tag", nil)
+	types.NewNamed(tag, types.NewInterfaceType(nil, nil).Complete(), nil)
+	pkg.Scope().Insert(tag)
+	pkg.MarkComplete()
+	return pkg
+}
+
+type importerFunc func(string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// typeCheck verifies that src is valid Go.
+func typeCheck(t *testing.T, src []byte) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "gen.go", src, 0)
+	if err != nil {
+		t.Fatalf("parsing generated code: %s", err)
+	}
+	nbtPkg := fakeNBT()
+	conf := types.Config{Importer: importerFunc(func(string) (*types.Package, error) { return nbtPkg, nil })}
+	if _, err = conf.Check("model", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("type-checking generated code: %s\n%s", err, src)
+	}
+}
+
+func mustSNBT(t *testing.T, s string) nbt.Tag {
+	tag, err := nbt.ParseSNBT(s)
+	if err != nil {
+		t.Fatalf("parsing %q: %s", s, err)
+	}
+	return tag
+}
+
+func TestGenerate(t *testing.T) {
+	samples := []string{
+		`{a:1,b:"x",c:{d:1b},ints:[1,2],longs:[L;1L],k:"s",x:{pos:{a:1}},y:{pos:{b:1}},"a-b":1,a_b:2}`,
+		`{a:2L,c:{d:2b,e:1.5f},ints:[3],empty:[],k:1,x:{pos:{a:2}},y:{pos:{b:2}},"a-b":1,a_b:2}`,
+	}
+	root := &shape{}
+	for _, s := range samples {
+		root.merge(mustSNBT(t, s))
+	}
+	out, err := generateTypes(root, "model", "Root", []string{"one", "two"})
+	if err != nil {
+		t.Fatalf("generating: %s", err)
+	}
+	src := string(out)
+	typeCheck(t, out)
+	want := []string{
+		"// Code generated by nbt2go from one, two; DO NOT EDIT.",
+		`import "github.com/seebs/nbt"`,
+		// widened from Int and Long
+		"A int64 `nbt:\"a\"`",
+		// missing from one sample
+		"B *string `nbt:\"b,omitempty\"`",
+		"Empty []nbt.Tag `nbt:\"empty,omitempty\"`",
+		"C C `nbt:\"c\"`",
+		"D int8 `nbt:\"d\"`",
+		"E *float32 `nbt:\"e,omitempty\"`",
+		"Ints []int32 `nbt:\"ints,list\"`",
+		"Longs []int64 `nbt:\"longs,omitempty\"`",
+		// conflicting types
+		"K nbt.Tag `nbt:\"k\"`",
+		// deduplicated names
+		"Pos Pos `nbt:\"pos\"`",
+		"Pos YPos `nbt:\"pos\"`",
+		"AB int32 `nbt:\"a-b\"`",
+		"AB2 int32 `nbt:\"a_b\"`",
+	}
+	// collapse alignment whitespace
+	fields := make(map[string]bool)
+	for _, line := range strings.Split(src, "\n") {
+		fields[strings.Join(strings.Fields(line), " ")] = true
+	}
+	for _, w := range want {
+		if !fields[w] {
+			t.Errorf("missing %s in:\n%s", w, src)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	cases := map[string]string{
+		"name":             "Name",
+		"created-on":       "CreatedOn",
+		"listTest (long)":  "ListTestLong",
+		"2d":               "X2d",
+		"":                 "X",
+		"minecraft:health": "MinecraftHealth",
+	}
+	for in, want := range cases {
+		if got := identifier(in); got != want {
+			t.Errorf("identifier(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// The nbt2go command reads sample NBT files, and writes Go struct types
+// matching their structure, as a starting point for typed models of
+// files like level.dat.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/seebs/nbt"
+)
+
+func main() {
+	pkg := flag.String("package", "model", "package name for generated code")
+	typeName := flag.String("type", "Root", "name of the top-level type")
+	outfile := flag.String("out", "", "output file name (default stdout)")
+	uncompressed := flag.Bool("u", false, "input files are uncompressed")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatalf("usage: nbt2go [-package name] [-type name] [-out file] [-u] file...")
+	}
+	load := nbt.Load
+	if *uncompressed {
+		load = nbt.LoadUncompressed
+	}
+	root := &shape{}
+	for _, file := range flag.Args() {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("open: %s", err)
+		}
+		t, _, err := load(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("load %s: %s", file, err)
+		}
+		if t.Type() != nbt.TypeCompound {
+			log.Fatalf("%s: top-level tag is %v, not Compound", file, t.Type())
+		}
+		root.merge(t)
+	}
+	out, err := generateTypes(root, *pkg, *typeName, flag.Args())
+	if err != nil {
+		log.Fatalf("formatting generated code: %s", err)
+	}
+	if *outfile == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err = os.WriteFile(*outfile, out, 0o644); err != nil {
+		log.Fatalf("writing %s: %s", *outfile, err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", *outfile)
+}
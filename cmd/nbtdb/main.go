@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/seebs/gogetopt"
 	"github.com/seebs/nbt"
@@ -12,13 +13,13 @@ import (
 )
 
 func main() {
-	opts, files, err := gogetopt.GetOpt(os.Args[1:], "u")
+	opts, files, err := gogetopt.GetOpt(os.Args[1:], "cu")
 	if err != nil {
 		log.Fatalf("invalid args: %s", err)
 	}
 
 	if len(files) != 1 {
-		log.Fatalf("usage: nbtdb [-u] file")
+		log.Fatalf("usage: nbtdb [-cu] file")
 	}
 
 	load := nbt.Load
@@ -38,22 +39,28 @@ func main() {
 			os.Exit(1)
 		}
 		editor := newEditor(t)
+		editor.printer.Color = opts.Seen("c")
 		editor.run()
 	}
 }
 
 type editor struct {
-	path nbt.Path
-	cmds map[string]handler
+	path    nbt.Path
+	cmds    map[string]handler
+	printer nbt.Printer
 }
 
 type handler func([]string) error
 
 func newEditor(t nbt.Tag) *editor {
 	e := &editor{path: nbt.NewPath(t)}
+	e.printer = nbt.DefaultPrinter
+	e.printer.ArrayPreview = 16
 	e.cmds = make(map[string]handler)
 	e.cmds["ls"] = e.doLs
 	e.cmds["cd"] = e.doCd
+	e.cmds["p"] = e.doPrint
+	e.cmds["print"] = e.doPrint
 	return e
 }
 
@@ -69,6 +76,19 @@ func (e *editor) doLs(args []string) error {
 	return nil
 }
 
+// doPrint prints the current node, optionally only to a given depth.
+func (e *editor) doPrint(args []string) error {
+	p := e.printer
+	if len(args) > 0 {
+		depth, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid depth %q", args[0])
+		}
+		p.MaxDepth = depth
+	}
+	return p.Print(os.Stdout, e.path.Current())
+}
+
 func (e *editor) doCd(args []string) error {
 	_, err := e.path.Cd(nbt.String(args[0]))
 	return err
@@ -127,7 +147,7 @@ func listContents(t nbt.Tag) {
 	case nbt.List:
 		tag.Iterate(func(i int, t nbt.Tag) error { fmt.Printf("%d\n", i); return nil })
 	case nbt.Compound:
-		for k := range tag {
+		for k := range tag.Sorted() {
 			fmt.Printf("%s\n", k)
 		}
 	default:
@@ -0,0 +1,132 @@
+// The nbtdu command reports which parts of NBT files take up the most
+// space, like du does for directories.
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/seebs/nbt"
+)
+
+// usage is the space used by one node.
+type usage struct {
+	path string
+	size int
+	tag  nbt.Tag
+	// name and header are set for nodes stored with headers, which are
+	// the root and compound entries
+	name   nbt.String
+	header bool
+}
+
+// compressedSize estimates the size of a node after compression, by
+// deflating its stored form, header included. Each node is compressed
+// separately, so the work grows with the depth of the tree, but only
+// reported nodes are compressed. Compound entries are written in sorted
+// order, rather than stored order, so the estimate is the same on every
+// run.
+func compressedSize(u usage) int {
+	buf := &bytes.Buffer{}
+	fw, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	if u.header {
+		e := nbt.NewEncoder(fw)
+		e.Header(u.tag.Type(), string(u.name))
+		e.Flush()
+	}
+	nbt.StoreSorted(fw, u.tag)
+	fw.Close()
+	return buf.Len()
+}
+
+// pathString formats a path like a file name.
+func pathString(p nbt.Path) string {
+	if len(p.Components) == 0 {
+		return "/"
+	}
+	parts := make([]string, len(p.Components))
+	for i, c := range p.Components {
+		parts[i] = fmt.Sprint(c)
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// measure finds the size of every node in t, down to maxDepth if it's
+// positive. Nodes in compounds include their headers.
+func measure(t nbt.Tag, name nbt.String, maxDepth int) []usage {
+	var out []usage
+	nbt.Walk(t, func(p nbt.Path, t nbt.Tag) error {
+		u := usage{path: pathString(p), size: nbt.EncodedSize(t), tag: t}
+		switch {
+		case len(p.Components) == 0:
+			u.name, u.header = name, true
+			u.size += 3 + len(name)
+		case p.Tags[len(p.Tags)-2].Type() == nbt.TypeCompound:
+			u.name, u.header = p.Components[len(p.Components)-1].(nbt.String), true
+			u.size += 3 + len(u.name)
+		}
+		out = append(out, u)
+		if maxDepth > 0 && len(p.Components) >= maxDepth {
+			return nbt.SkipSubtree
+		}
+		return nil
+	})
+	return out
+}
+
+// largest yields the n largest of usages, or all of them if n isn't
+// positive, largest first. Nodes of the same size stay in tree order.
+func largest(usages []usage, n int) []usage {
+	sort.SliceStable(usages, func(i, j int) bool { return usages[i].size > usages[j].size })
+	if n > 0 && len(usages) > n {
+		usages = usages[:n]
+	}
+	return usages
+}
+
+func main() {
+	top := flag.Int("n", 20, "number of entries to show (0 for all)")
+	maxDepth := flag.Int("d", 0, "maximum depth to report (0 for unlimited)")
+	uncompressed := flag.Bool("u", false, "input files are uncompressed")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatalf("usage: nbtdu [-n count] [-d depth] [-u] file...")
+	}
+	load := nbt.Load
+	if *uncompressed {
+		load = nbt.LoadUncompressed
+	}
+	failed := false
+	for _, file := range flag.Args() {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open: %s\n", err)
+			failed = true
+			continue
+		}
+		t, name, err := load(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load %s: %s\n", file, err)
+			failed = true
+			continue
+		}
+		usages := largest(measure(t, name, *maxDepth), *top)
+		if flag.NArg() > 1 {
+			fmt.Printf("%s:\n", file)
+		}
+		fmt.Printf("%10s %10s  %s\n", "bytes", "deflated", "path")
+		for _, u := range usages {
+			fmt.Printf("%10d %10d  %s\n", u.size, compressedSize(u), u.path)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
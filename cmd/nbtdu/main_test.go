@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"testing"
+
+	"github.com/seebs/nbt"
+)
+
+func testTree() nbt.Compound {
+	return nbt.Compound{
+		"ab": nbt.Compound{"c": nbt.Int(1)},
+		"l":  nbt.MakeCompoundList([]nbt.Compound{{"x": nbt.Byte(2)}}),
+		"m":  nbt.MustMakeMixedList([]nbt.Tag{nbt.Int(3), nbt.String("s")}),
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	root := testTree()
+	sizes := map[string]usage{}
+	for _, u := range measure(root, "name", 0) {
+		sizes[u.path] = u
+	}
+	cases := []struct {
+		path   string
+		size   int
+		header bool
+	}{
+		// headers are 1 byte of type, 2 of length, and the name
+		{"/", 3 + 4 + nbt.EncodedSize(root), true},
+		{"/ab", 3 + 2 + (3 + 1 + 4) + 1, true},
+		{"/ab/c", 3 + 1 + 4, true},
+		{"/l", 3 + 1 + nbt.EncodedSize(root["l"]), true},
+		// list elements have no headers
+		{"/l/0", (3 + 1 + 1) + 1, false},
+		{"/l/0/x", 3 + 1 + 1, true},
+		{"/m/0", 4, false},
+		{"/m/1", 3, false},
+	}
+	for _, c := range cases {
+		u, ok := sizes[c.path]
+		if !ok {
+			t.Errorf("%s: not measured", c.path)
+			continue
+		}
+		if u.size != c.size || u.header != c.header {
+			t.Errorf("%s: expected size %d, header %t, got %d, %t", c.path, c.size, c.header, u.size, u.header)
+		}
+	}
+	if len(sizes) != len(cases)+1 {
+		t.Errorf("expected %d nodes, got %d", len(cases)+1, len(sizes))
+	}
+	shallow := measure(root, "name", 1)
+	if len(shallow) != 4 {
+		t.Errorf("depth 1: expected 4 nodes, got %d", len(shallow))
+	}
+	for _, u := range shallow {
+		if u.path != "/" && sizes[u.path].size != u.size {
+			t.Errorf("depth 1: %s: expected size %d, got %d", u.path, sizes[u.path].size, u.size)
+		}
+	}
+}
+
+func TestLargest(t *testing.T) {
+	usages := []usage{{path: "a", size: 1}, {path: "b", size: 3}, {path: "c", size: 2}, {path: "d", size: 3}}
+	got := largest(append([]usage{}, usages...), 3)
+	want := []string{"b", "d", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		if got[i].path != w {
+			t.Errorf("entry %d: expected %s, got %s", i, w, got[i].path)
+		}
+	}
+	if all := largest(append([]usage{}, usages...), 0); len(all) != len(usages) {
+		t.Errorf("n=0: expected all %d entries, got %d", len(usages), len(all))
+	}
+}
+
+func TestCompressedSize(t *testing.T) {
+	root := testTree()
+	for i := 0; i < 20; i++ {
+		root[nbt.String(rune('a'+i))] = nbt.Int(i)
+	}
+	u := usage{tag: root, name: "name", header: true}
+	want := compressedSize(u)
+	for i := 0; i < 10; i++ {
+		if got := compressedSize(u); got != want {
+			t.Fatalf("compressed size varies: %d, then %d", want, got)
+		}
+	}
+	// the estimate is for the header and payload
+	buf := &bytes.Buffer{}
+	fw, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	nbt.StoreTag(fw, root, "name")
+	fw.Close()
+	compressed := buf.Len()
+	raw, err := io.ReadAll(flate.NewReader(buf))
+	if err != nil || len(raw) != 3+4+nbt.EncodedSize(root) {
+		t.Fatalf("inflated %d bytes, expected %d: %v", len(raw), 3+4+nbt.EncodedSize(root), err)
+	}
+	if diff := want - compressed; diff < -16 || diff > 16 {
+		t.Errorf("estimate %d is far from compressed size %d", want, compressed)
+	}
+}
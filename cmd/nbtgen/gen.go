@@ -0,0 +1,397 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// kind describes how a Go type is stored.
+type kind struct {
+	// typ is the name of the nbt.Type constant, without "Type"
+	typ string
+	// method is the Encoder and Decoder method for scalars and arrays
+	method string
+	// goType is the Go type, for making slices
+	goType string
+	// conv is the basic type a named type has to be converted to and
+	// from, such as int32 for a type Meters int32
+	conv string
+	// elem is the element kind, for lists
+	elem *kind
+	// compound is set for struct types, which have their own methods
+	compound bool
+	// tag is set for nbt.Tag, and tagList for []nbt.Tag
+	tag, tagList bool
+}
+
+// kinds are the Go types with a direct NBT equivalent.
+var kinds = map[string]*kind{
+	"int8":    {typ: "Byte", method: "Byte"},
+	"int16":   {typ: "Short", method: "Short"},
+	"int32":   {typ: "Int", method: "Int"},
+	"int64":   {typ: "Long", method: "Long"},
+	"float32": {typ: "Float", method: "Float"},
+	"float64": {typ: "Double", method: "Double"},
+	"string":  {typ: "String", method: "Text"},
+	"[]int8":  {typ: "ByteArray", method: "ByteArray"},
+	"[]int32": {typ: "IntArray", method: "IntArray"},
+	"[]int64": {typ: "LongArray", method: "LongArray"},
+}
+
+// isSlice indicates whether values of kind k are slices.
+func (k *kind) isSlice() bool {
+	return k.elem != nil || k.tagList || strings.HasPrefix(k.goType, "[]") || strings.HasPrefix(k.conv, "[]")
+}
+
+// field is a struct field to be stored under Key.
+type field struct {
+	Name      string
+	Key       string
+	OmitEmpty bool
+	Pointer   bool
+	kind      *kind
+}
+
+type structType struct {
+	Name   string
+	Fields []*field
+}
+
+// file is the data the template is executed with.
+type file struct {
+	Source  string
+	Package string
+	Structs []*structType
+}
+
+// resolver finds the declarations of the named types used by fields,
+// anywhere in the package.
+type resolver struct {
+	decls     map[string]ast.Expr
+	resolving map[string]bool
+}
+
+// newResolver collects the type declarations in the package containing
+// the file src, which is already parsed.
+func newResolver(name string, src *ast.File) (*resolver, error) {
+	r := &resolver{decls: make(map[string]ast.Expr), resolving: make(map[string]bool)}
+	r.add(src)
+	others, err := filepath.Glob(filepath.Join(filepath.Dir(name), "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, other := range others {
+		if strings.HasSuffix(other, "_test.go") || filepath.Base(other) == filepath.Base(name) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, other, nil, parser.SkipObjectResolution)
+		if err != nil || f.Name.Name != src.Name.Name {
+			// not part of the package, or not ours to complain about
+			continue
+		}
+		r.add(f)
+	}
+	return r, nil
+}
+
+// add records the type declarations in f.
+func (r *resolver) add(f *ast.File) {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			r.decls[ts.Name.Name] = ts.Type
+		}
+	}
+}
+
+// parseFile finds the struct types in a Go source file, or just the ones
+// named in only, if it's not empty. Types of fields can be declared
+// anywhere in the file's package.
+func parseFile(name string, only []string) (*file, error) {
+	fset := token.NewFileSet()
+	src, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newResolver(name, src)
+	if err != nil {
+		return nil, err
+	}
+	f := &file{Source: filepath.Base(name), Package: src.Name.Name}
+	wanted := make(map[string]bool)
+	for _, n := range only {
+		wanted[n] = true
+	}
+	for _, decl := range src.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || (len(only) != 0 && !wanted[ts.Name.Name]) {
+				continue
+			}
+			delete(wanted, ts.Name.Name)
+			s, err := r.parseStruct(ts.Name.Name, st)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", fset.Position(ts.Pos()), err)
+			}
+			f.Structs = append(f.Structs, s)
+		}
+	}
+	for n := range wanted {
+		return nil, fmt.Errorf("no struct type %s in %s", n, name)
+	}
+	return f, nil
+}
+
+// parseStruct finds the stored fields of a struct type.
+func (r *resolver) parseStruct(name string, st *ast.StructType) (*structType, error) {
+	s := &structType{Name: name}
+	for _, fd := range st.Fields.List {
+		if len(fd.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded fields are not supported", name)
+		}
+		var tag string
+		if fd.Tag != nil {
+			tag = reflect.StructTag(strings.Trim(fd.Tag.Value, "`")).Get("nbt")
+		}
+		if tag == "-" {
+			continue
+		}
+		key, opts := parseTag(tag)
+		for _, id := range fd.Names {
+			if !id.IsExported() {
+				continue
+			}
+			f := &field{Name: id.Name, Key: key, OmitEmpty: opts["omitempty"]}
+			if f.Key == "" {
+				f.Key = id.Name
+			}
+			typ := fd.Type
+			if star, ok := typ.(*ast.StarExpr); ok {
+				f.Pointer, typ = true, star.X
+			}
+			k, err := r.typeKind(typ, opts["list"])
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %s", name, id.Name, err)
+			}
+			if f.Pointer && (k.tag || k.isSlice()) {
+				return nil, fmt.Errorf("%s.%s: pointers are only supported to scalars and structs", name, id.Name)
+			}
+			f.kind = k
+			s.Fields = append(s.Fields, f)
+		}
+	}
+	return s, nil
+}
+
+// parseTag splits an nbt struct tag into the key and options. Keys can
+// contain commas, so only known options are recognized, at the end.
+func parseTag(tag string) (string, map[string]bool) {
+	opts := make(map[string]bool)
+	for {
+		i := strings.LastIndexByte(tag, ',')
+		if i < 0 || !knownOptions[tag[i+1:]] {
+			return tag, opts
+		}
+		opts[tag[i+1:]] = true
+		tag = tag[:i]
+	}
+}
+
+// knownOptions are the options which can follow the key in a struct tag.
+// The list option stores a slice of integers as a List rather than as an
+// array.
+var knownOptions = map[string]bool{
+	"omitempty": true,
+	"list":      true,
+}
+
+// typeKind determines how a type is stored. Named struct types are
+// expected to have generated methods of their own, and other named types
+// are stored as their underlying types, which must be basic types or
+// slices. If list is set, slices are always Lists, rather than arrays.
+func (r *resolver) typeKind(expr ast.Expr, list bool) (*kind, error) {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if k, ok := kinds[x.Name]; ok {
+			return &kind{typ: k.typ, method: k.method, goType: x.Name}, nil
+		}
+		return r.namedKind(x.Name, list)
+	case *ast.SelectorExpr:
+		if pkg, ok := x.X.(*ast.Ident); ok && pkg.Name == "nbt" && x.Sel.Name == "Tag" {
+			return &kind{goType: "nbt.Tag", tag: true}, nil
+		}
+	case *ast.ArrayType:
+		if x.Len != nil {
+			break
+		}
+		if id, ok := x.Elt.(*ast.Ident); ok && !list {
+			if k, ok := kinds["[]"+id.Name]; ok {
+				return &kind{typ: k.typ, method: k.method, goType: "[]" + id.Name}, nil
+			}
+		}
+		elem, err := r.typeKind(x.Elt, false)
+		if err != nil {
+			return nil, err
+		}
+		if elem.tag {
+			return &kind{typ: "List", goType: "[]nbt.Tag", tagList: true}, nil
+		}
+		return &kind{typ: "List", goType: "[]" + elem.goType, elem: elem}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", types(expr))
+}
+
+// namedKind determines how a named type declared in the package is
+// stored.
+func (r *resolver) namedKind(name string, list bool) (*kind, error) {
+	decl, ok := r.decls[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+	if _, ok := decl.(*ast.StructType); ok {
+		return &kind{typ: "Compound", goType: name, compound: true}, nil
+	}
+	switch decl.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.ArrayType:
+	default:
+		return nil, fmt.Errorf("unsupported type %s: named types must be structs, basic types, or slices, not %s", name, types(decl))
+	}
+	if r.resolving[name] {
+		return nil, fmt.Errorf("invalid recursive type %s", name)
+	}
+	r.resolving[name] = true
+	defer delete(r.resolving, name)
+	k, err := r.typeKind(decl, list)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	switch {
+	case k.elem != nil, k.tagList:
+		// slices convert implicitly
+		k.goType = name
+		return k, nil
+	case k.method == "":
+		return nil, fmt.Errorf("unsupported type %s: named types must be structs, basic types, or slices, not %s", name, types(decl))
+	}
+	conv := k.conv
+	if conv == "" {
+		conv = k.goType
+	}
+	return &kind{typ: k.typ, method: k.method, goType: name, conv: conv}, nil
+}
+
+// types formats a type expression for error messages.
+func types(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		return x.Name
+	case *ast.SelectorExpr:
+		return types(x.X) + "." + x.Sel.Name
+	case *ast.StarExpr:
+		return "*" + types(x.X)
+	case *ast.ArrayType:
+		return "[]" + types(x.Elt)
+	case *ast.MapType:
+		return "map[" + types(x.Key) + "]" + types(x.Value)
+	case *ast.FuncType:
+		return "func"
+	case *ast.InterfaceType:
+		return "interface"
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// encode yields code writing the payload of expr, of kind k. Loop
+// variables are numbered by depth so nested lists don't collide.
+func encode(expr string, k *kind, depth int) string {
+	switch {
+	case k.compound:
+		return fmt.Sprintf("%s.MarshalNBT(e)", expr)
+	case k.tag:
+		return fmt.Sprintf("e.Tag(%s)", expr)
+	case k.tagList:
+		return fmt.Sprintf("e.TagList(%s)", expr)
+	case k.elem != nil:
+		i := fmt.Sprintf("i%d", depth)
+		return fmt.Sprintf("e.ListHeader(nbt.Type%s, len(%s))\nfor %s := range %s {\n%s\n}",
+			k.elem.typ, expr, i, expr, encode(expr+"["+i+"]", k.elem, depth+1))
+	}
+	if k.conv != "" {
+		expr = k.conv + "(" + expr + ")"
+	}
+	return fmt.Sprintf("e.%s(%s)", k.method, expr)
+}
+
+// decode yields code reading a payload of kind k into target.
+func decode(target string, k *kind, depth int) string {
+	switch {
+	case k.compound:
+		return fmt.Sprintf("%s.UnmarshalNBT(d)", target)
+	case k.tagList:
+		return fmt.Sprintf("%s = d.TagList()", target)
+	case k.elem != nil:
+		i := fmt.Sprintf("i%d", depth)
+		return fmt.Sprintf("%s = make(%s, d.ListHeader(nbt.Type%s))\nfor %s := range %s {\n%s\n}",
+			target, k.goType, k.elem.typ, i, target, decode(target+"["+i+"]", k.elem, depth+1))
+	}
+	if k.conv != "" {
+		return fmt.Sprintf("%s = %s(d.%s())", target, k.goType, k.method)
+	}
+	return fmt.Sprintf("%s = d.%s()", target, k.method)
+}
+
+// marshalField yields the code in MarshalNBT for f.
+func marshalField(f *field) string {
+	expr := "x." + f.Name
+	k := f.kind
+	if k.tag {
+		return fmt.Sprintf("if %s != nil {\ne.Header(%s.Type(), %q)\ne.Tag(%s)\n}", expr, expr, f.Key, expr)
+	}
+	var cond string
+	switch {
+	case f.Pointer:
+		cond = expr + " != nil"
+		if !k.compound {
+			expr = "*" + expr
+		}
+	case f.OmitEmpty && k.isSlice():
+		cond = "len(" + expr + ") != 0"
+	}
+	code := fmt.Sprintf("e.Header(nbt.Type%s, %q)\n%s", k.typ, f.Key, encode(expr, k, 0))
+	if cond != "" {
+		code = fmt.Sprintf("if %s {\n%s\n}", cond, code)
+	}
+	return code
+}
+
+// unmarshalField yields the code in UnmarshalNBT for f.
+func unmarshalField(f *field) string {
+	target := "x." + f.Name
+	k := f.kind
+	if k.tag {
+		return fmt.Sprintf("%s = d.Tag(typ)", target)
+	}
+	code := decode(target, k, 0)
+	if f.Pointer {
+		if !k.compound {
+			target = "*" + target
+		}
+		code = fmt.Sprintf("x.%s = new(%s)\n%s", f.Name, k.goType, decode(target, k, 0))
+	}
+	return fmt.Sprintf("if d.Expect(typ, nbt.Type%s, name) {\n%s\n}", k.typ, code)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type importerFunc func(string) (*gotypes.Package, error)
+
+func (f importerFunc) Import(path string) (*gotypes.Package, error) { return f(path) }
+
+// checkNBT type-checks the nbt package itself, from the source two
+// directories up, so generated code can be checked against the real API.
+func checkNBT(t *testing.T, fset *token.FileSet, std gotypes.Importer) *gotypes.Package {
+	names, err := filepath.Glob("../../*.go")
+	if err != nil {
+		t.Fatalf("listing nbt sources: %s", err)
+	}
+	var files []*ast.File
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatalf("parsing %s: %s", name, err)
+		}
+		files = append(files, f)
+	}
+	conf := gotypes.Config{Importer: std}
+	pkg, err := conf.Check("github.com/seebs/nbt", fset, files, nil)
+	if err != nil {
+		t.Fatalf("type-checking nbt: %s", err)
+	}
+	return pkg
+}
+
+// typeCheck verifies that the generated code compiles along with the
+// source it was generated from.
+func typeCheck(t *testing.T, src string, out []byte) {
+	fset := token.NewFileSet()
+	std := importer.Default()
+	nbtPkg := checkNBT(t, fset, std)
+	var files []*ast.File
+	for name, text := range map[string][]byte{"types.go": []byte(src), "types_nbt.go": out} {
+		f, err := parser.ParseFile(fset, name, text, 0)
+		if err != nil {
+			t.Fatalf("parsing %s: %s\n%s", name, err, text)
+		}
+		files = append(files, f)
+	}
+	conf := gotypes.Config{Importer: importerFunc(func(path string) (*gotypes.Package, error) {
+		if path == nbtPkg.Path() {
+			return nbtPkg, nil
+		}
+		return std.Import(path)
+	})}
+	if _, err := conf.Check("model", fset, files, nil); err != nil {
+		t.Fatalf("type-checking generated code: %s\n%s", err, out)
+	}
+}
+
+// writeSource writes src to a file in a new directory, and yields its
+// name.
+func writeSource(t *testing.T, src string) string {
+	name := filepath.Join(t.TempDir(), "types.go")
+	if err := os.WriteFile(name, []byte(src), 0o644); err != nil {
+		t.Fatalf("writing source: %s", err)
+	}
+	return name
+}
+
+// squash collapses runs of whitespace, so expected code doesn't have to
+// match gofmt's alignment.
+func squash(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+const genSource = `package model
+
+import "github.com/seebs/nbt"
+
+type Meters int32
+
+type IDs []int32
+
+type Names []string
+
+type Root struct {
+	Count   *int32             ` + "`nbt:\"count\"`" + `
+	Child   *Child             ` + "`nbt:\"child\"`" + `
+	Items   []Child            ` + "`nbt:\"items,omitempty\"`" + `
+	Grid    [][]int32          ` + "`nbt:\"grid\"`" + `
+	Extra   nbt.Tag            ` + "`nbt:\"extra\"`" + `
+	Many    []nbt.Tag          ` + "`nbt:\"many,omitempty\"`" + `
+	Odd     int8               ` + "`nbt:\"a, b,omitempty\"`" + `
+	Listed  []int32            ` + "`nbt:\"listed,list\"`" + `
+	Dist    Meters             ` + "`nbt:\"dist\"`" + `
+	MaybeM  *Meters            ` + "`nbt:\"maybe\"`" + `
+	IDs     IDs                ` + "`nbt:\"ids,omitempty\"`" + `
+	Names   Names              ` + "`nbt:\"names\"`" + `
+	Skipped string             ` + "`nbt:\"-\"`" + `
+}
+
+type Child struct {
+	Name string
+}
+`
+
+func TestGenerate(t *testing.T) {
+	name := writeSource(t, genSource)
+	f, err := parseFile(name, nil)
+	if err != nil {
+		t.Fatalf("parsing: %s", err)
+	}
+	out, err := generate(f)
+	if err != nil {
+		t.Fatalf("generating: %s", err)
+	}
+	typeCheck(t, genSource, out)
+	src := squash(string(out))
+	want := []string{
+		// pointer to scalar
+		`if x.Count != nil { e.Header(nbt.TypeInt, "count") e.Int(*x.Count) }`,
+		`x.Count = new(int32) *x.Count = d.Int()`,
+		// pointer to struct
+		`if x.Child != nil { e.Header(nbt.TypeCompound, "child") x.Child.MarshalNBT(e) }`,
+		`x.Child = new(Child) x.Child.UnmarshalNBT(d)`,
+		// omitempty list of structs
+		`if len(x.Items) != 0 { e.Header(nbt.TypeList, "items") e.ListHeader(nbt.TypeCompound, len(x.Items))`,
+		// nested lists
+		`e.ListHeader(nbt.TypeIntArray, len(x.Grid)) for i0 := range x.Grid { e.IntArray(x.Grid[i0]) }`,
+		`x.Grid = make([][]int32, d.ListHeader(nbt.TypeIntArray))`,
+		// arbitrary tags
+		`if x.Extra != nil { e.Header(x.Extra.Type(), "extra") e.Tag(x.Extra) }`,
+		`x.Extra = d.Tag(typ)`,
+		`if len(x.Many) != 0 { e.Header(nbt.TypeList, "many") e.TagList(x.Many) }`,
+		// comma in key
+		`e.Header(nbt.TypeByte, "a, b")`,
+		`case "a, b":`,
+		// list option
+		`e.ListHeader(nbt.TypeInt, len(x.Listed)) for i0 := range x.Listed { e.Int(x.Listed[i0]) }`,
+		// named types
+		`e.Int(int32(x.Dist))`,
+		`x.Dist = Meters(d.Int())`,
+		`x.MaybeM = new(Meters) *x.MaybeM = Meters(d.Int())`,
+		`if len(x.IDs) != 0 { e.Header(nbt.TypeIntArray, "ids") e.IntArray([]int32(x.IDs)) }`,
+		`x.IDs = IDs(d.IntArray())`,
+		`x.Names = make(Names, d.ListHeader(nbt.TypeString))`,
+		// default key
+		`case "Name":`,
+	}
+	for _, w := range want {
+		if !strings.Contains(src, w) {
+			t.Errorf("generated code lacks %q", w)
+		}
+	}
+	if strings.Contains(src, "Skipped") {
+		t.Errorf("generated code includes skipped field")
+	}
+	if t.Failed() {
+		t.Logf("generated:\n%s", out)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := []struct {
+		src, err string
+	}{
+		{"type M map[string]int32\ntype S struct{ M M }", "unsupported type M"},
+		{"type F func()\ntype S struct{ F F }", "unsupported type F"},
+		{"type S struct{ U Unknown }", "unknown type Unknown"},
+		{"type R []R\ntype S struct{ R R }", "invalid recursive type R"},
+		{"type S struct{ P *[]int32 }", "pointers are only supported"},
+	}
+	for _, c := range cases {
+		_, err := parseFile(writeSource(t, "package model\n"+c.src+"\n"), nil)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q: expected error containing %q, got %v", c.src, c.err, err)
+		}
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	cases := []struct {
+		tag, key string
+		opts     string
+	}{
+		{"a", "a", ""},
+		{"a,omitempty", "a", "omitempty"},
+		{"a, b,omitempty,list", "a, b", "list,omitempty"},
+		{"a,b", "a,b", ""},
+	}
+	for _, c := range cases {
+		key, opts := parseTag(c.tag)
+		var got []string
+		for _, o := range []string{"list", "omitempty"} {
+			if opts[o] {
+				got = append(got, o)
+			}
+		}
+		if key != c.key || strings.Join(got, ",") != c.opts {
+			t.Errorf("%q: expected %q [%s], got %q [%s]", c.tag, c.key, c.opts, key, strings.Join(got, ","))
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// The nbtgen command generates MarshalNBT and UnmarshalNBT methods for Go
+// struct types, which read and write NBT data directly using nbt.Encoder
+// and nbt.Decoder, without building a tree of Tags. Like typegen, it
+// fills in a text/template; the types come from a Go source file, such as
+// one written by nbt2go. Typical use is a go:generate line:
+//
+//	//go:generate go run github.com/seebs/nbt/cmd/nbtgen -in level.go -out level_nbt.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+)
+
+func main() {
+	infile := flag.String("in", "", "input file name (containing struct types)")
+	outfile := flag.String("out", "", "output file name (default stdout)")
+	types := flag.String("type", "", "comma-separated types to generate methods for (default all structs)")
+	flag.Parse()
+	if *infile == "" {
+		log.Fatal("-in must be specified")
+	}
+	var only []string
+	if *types != "" {
+		only = strings.Split(*types, ",")
+	}
+	f, err := parseFile(*infile, only)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	out, err := generate(f)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	if *outfile == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err = os.WriteFile(*outfile, out, 0o644); err != nil {
+		log.Fatalf("writing %s: %s", *outfile, err)
+	}
+}
+
+// generate yields the formatted source of the methods for the types in f.
+func generate(f *file) ([]byte, error) {
+	tmpl := template.Must(template.New("nbtgen").Funcs(template.FuncMap{
+		"marshal":   marshalField,
+		"unmarshal": unmarshalField,
+	}).Parse(methods))
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, f); err != nil {
+		return nil, fmt.Errorf("template failed: %s", err)
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %s", err)
+	}
+	return out, nil
+}
+
+// methods is the template for the generated file.
+const methods = `// Code generated by nbtgen from {{.Source}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/seebs/nbt"
+{{range .Structs}}
+// MarshalNBT writes x as the payload of a Compound.
+func (x *{{.Name}}) MarshalNBT(e *nbt.Encoder) error {
+{{- range .Fields}}
+{{marshal .}}
+{{- end}}
+	e.End()
+	return e.Err()
+}
+
+// UnmarshalNBT reads x from the payload of a Compound. Entries with
+// unknown keys are skipped.
+func (x *{{.Name}}) UnmarshalNBT(d *nbt.Decoder) error {
+	for {
+		typ, name := d.Header()
+		if typ == nbt.TypeEnd {
+			break
+		}
+		switch string(name) {
+{{- range .Fields}}
+		case {{printf "%q" .Key}}:
+{{unmarshal .}}
+{{- end}}
+		default:
+			d.Skip(typ)
+		}
+	}
+	return d.Err()
+}
+{{end}}`
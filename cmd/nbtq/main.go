@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+
+	"github.com/seebs/gogetopt"
+	"github.com/seebs/nbt"
+	"github.com/seebs/nbt/query"
+)
+
+func main() {
+	opts, args, err := gogetopt.GetOpt(os.Args[1:], "uj")
+	if err != nil {
+		log.Fatalf("invalid args: %s", err)
+	}
+
+	if len(args) < 2 {
+		log.Fatalf("usage: nbtq [-u] [-j] query file...")
+	}
+
+	q, err := query.Parse(args[0])
+	if err != nil {
+		log.Fatalf("query: %s", err)
+	}
+
+	load := nbt.Load
+	if opts.Seen("u") {
+		load = nbt.LoadUncompressed
+	}
+	format := formatSNBT
+	if opts.Seen("j") {
+		format = formatJSON
+	}
+
+	failed := false
+	for _, file := range args[1:] {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open: %s\n", err)
+			failed = true
+			continue
+		}
+		t, _, err := load(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load %s: %s\n", file, err)
+			failed = true
+			continue
+		}
+		err = q.Each(t, func(r nbt.Tag) error {
+			out, err := format(r)
+			if err != nil {
+				return err
+			}
+			fmt.Println(out)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatSNBT(t nbt.Tag) (string, error) {
+	return nbt.FormatSNBT(t), nil
+}
+
+func formatJSON(t nbt.Tag) (string, error) {
+	out, err := json.Marshal(jsonValue(t))
+	return string(out), err
+}
+
+// jsonValue converts t to a value encoding/json can handle. Type
+// information is lost, as it would be in any JSON representation, and so
+// are NaNs and infinities, which JSON can't represent, and which become
+// null.
+func jsonValue(t nbt.Tag) interface{} {
+	switch x := t.(type) {
+	case nbt.Compound:
+		out := make(map[string]interface{}, len(x))
+		for k, v := range x.Sorted() {
+			out[string(k)] = jsonValue(v)
+		}
+		return out
+	case nbt.List:
+		out := make([]interface{}, 0, x.Length())
+		x.Iterate(func(_ int, v nbt.Tag) error {
+			out = append(out, jsonValue(v))
+			return nil
+		})
+		return out
+	case nbt.End:
+		return nil
+	case nbt.Byte:
+		return int64(x)
+	case nbt.Short:
+		return int64(x)
+	case nbt.Int:
+		return int64(x)
+	case nbt.Long:
+		return int64(x)
+	case nbt.Float:
+		return jsonFloat(float64(x))
+	case nbt.Double:
+		return jsonFloat(float64(x))
+	case nbt.String:
+		return string(x)
+	case nbt.ByteArray:
+		out := make([]int64, len(x))
+		for i, v := range x {
+			out[i] = int64(v)
+		}
+		return out
+	case nbt.IntArray:
+		out := make([]int64, len(x))
+		for i, v := range x {
+			out[i] = int64(v)
+		}
+		return out
+	case nbt.LongArray:
+		out := make([]int64, len(x))
+		for i, v := range x {
+			out[i] = int64(v)
+		}
+		return out
+	default:
+		// custom types have no JSON form, but do have SNBT
+		return nbt.FormatSNBT(x)
+	}
+}
+
+// jsonFloat yields f, or nil if it's not finite.
+func jsonFloat(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
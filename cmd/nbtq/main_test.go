@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/seebs/nbt"
+)
+
+func TestFormatJSON(t *testing.T) {
+	cases := []struct {
+		tag  nbt.Tag
+		want string
+	}{
+		{nbt.Compound{"b": nbt.Byte(-1), "s": nbt.String("x"), "l": nbt.Long(math.MaxInt64)}, `{"b":-1,"l":9223372036854775807,"s":"x"}`},
+		{nbt.Double(1.5), `1.5`},
+		{nbt.Float(float32(math.NaN())), `null`},
+		{nbt.MakeDoubleList([]nbt.Double{1, nbt.Double(math.Inf(1)), nbt.Double(math.Inf(-1))}), `[1,null,null]`},
+		{nbt.ByteArray{-1, 2}, `[-1,2]`},
+		{nbt.IntArray{3}, `[3]`},
+		{nbt.LongArray{}, `[]`},
+		{nbt.End{}, `null`},
+	}
+	for _, c := range cases {
+		got, err := formatJSON(c.tag)
+		if err != nil {
+			t.Errorf("%s: %s", nbt.FormatSNBT(c.tag), err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %s, got %s", nbt.FormatSNBT(c.tag), c.want, got)
+		}
+	}
+}
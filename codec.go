@@ -0,0 +1,427 @@
+package nbt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// Streaming encoding and decoding, for the MarshalNBT and UnmarshalNBT
+// methods generated by cmd/nbtgen, which read and write Go values
+// directly rather than going through a tree of Tags.
+//
+// Errors are sticky: once an Encoder or Decoder has failed, every later
+// call does nothing, and the first error is reported by Err. Generated
+// code can thus make every call unconditionally and check once at the
+// end.
+
+// Marshaler is implemented by types which can write themselves as the
+// payload of a Compound, entries followed by the End tag.
+type Marshaler interface {
+	MarshalNBT(e *Encoder) error
+}
+
+// Unmarshaler is implemented by types which can read themselves from the
+// payload of a Compound.
+type Unmarshaler interface {
+	UnmarshalNBT(d *Decoder) error
+}
+
+// Encoder writes NBT data to a buffered stream.
+type Encoder struct {
+	w   *bufio.Writer
+	buf [8]byte
+	err error
+}
+
+// NewEncoder yields an Encoder writing to w. Output is buffered, so
+// call Flush when done.
+func NewEncoder(w io.Writer) *Encoder {
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriter(w)
+	}
+	return &Encoder{w: bw}
+}
+
+// Err yields the first error the Encoder encountered, if any.
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// Flush writes any buffered data, and reports the first error the Encoder
+// encountered, if any.
+func (e *Encoder) Flush() error {
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+func (e *Encoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+// Header writes the type and name which precede a value in a Compound.
+func (e *Encoder) Header(typ Type, name string) {
+	e.buf[0] = byte(typ)
+	e.write(e.buf[:1])
+	e.Text(name)
+}
+
+// End writes the End tag which terminates a Compound.
+func (e *Encoder) End() {
+	e.buf[0] = 0
+	e.write(e.buf[:1])
+}
+
+// Byte writes a Byte payload.
+func (e *Encoder) Byte(v int8) {
+	e.buf[0] = byte(v)
+	e.write(e.buf[:1])
+}
+
+// Short writes a Short payload.
+func (e *Encoder) Short(v int16) {
+	binary.BigEndian.PutUint16(e.buf[:2], uint16(v))
+	e.write(e.buf[:2])
+}
+
+// Int writes an Int payload.
+func (e *Encoder) Int(v int32) {
+	binary.BigEndian.PutUint32(e.buf[:4], uint32(v))
+	e.write(e.buf[:4])
+}
+
+// Long writes a Long payload.
+func (e *Encoder) Long(v int64) {
+	binary.BigEndian.PutUint64(e.buf[:8], uint64(v))
+	e.write(e.buf[:8])
+}
+
+// Float writes a Float payload.
+func (e *Encoder) Float(v float32) {
+	e.Int(int32(math.Float32bits(v)))
+}
+
+// Double writes a Double payload.
+func (e *Encoder) Double(v float64) {
+	e.Long(int64(math.Float64bits(v)))
+}
+
+// Text writes a String payload. It's not called String, to match the
+// Decoder, which would otherwise be a fmt.Stringer.
+func (e *Encoder) Text(v string) {
+	if len(v) > 32767 {
+		if e.err == nil {
+			e.err = fmt.Errorf("can't store %d-byte string", len(v))
+		}
+		return
+	}
+	e.Short(int16(len(v)))
+	if e.err == nil {
+		_, e.err = e.w.WriteString(v)
+	}
+}
+
+// ByteArray writes a ByteArray payload.
+func (e *Encoder) ByteArray(v []int8) {
+	e.Int(int32(len(v)))
+	e.write(*(*[]byte)(unsafe.Pointer(&v)))
+}
+
+// IntArray writes an IntArray payload.
+func (e *Encoder) IntArray(v []int32) {
+	e.Int(int32(len(v)))
+	for _, x := range v {
+		e.Int(x)
+	}
+}
+
+// LongArray writes a LongArray payload.
+func (e *Encoder) LongArray(v []int64) {
+	e.Int(int32(len(v)))
+	for _, x := range v {
+		e.Long(x)
+	}
+}
+
+// ListHeader writes the element type and length which begin a List
+// payload. The caller then writes n payloads of that type.
+func (e *Encoder) ListHeader(typ Type, n int) {
+	if n == 0 {
+		typ = TypeEnd
+	}
+	e.buf[0] = byte(typ)
+	e.write(e.buf[:1])
+	e.Int(int32(n))
+}
+
+// Tag writes the payload of an arbitrary tag.
+func (e *Encoder) Tag(t Tag) {
+	if e.err == nil {
+		e.err = t.Store(e.w)
+	}
+}
+
+// TagList writes a List payload containing ts, which may be of any
+// types, as with MakeMixedList.
+func (e *Encoder) TagList(ts []Tag) {
+	if e.err != nil {
+		return
+	}
+	var l List
+	if l, e.err = MakeMixedList(ts); e.err == nil {
+		e.err = l.Store(e.w)
+	}
+}
+
+// Marshal writes v to w as an uncompressed Compound with the given name.
+func Marshal(w io.Writer, v Marshaler, name String) error {
+	e := NewEncoder(w)
+	e.Header(TypeCompound, string(name))
+	v.MarshalNBT(e)
+	return e.Flush()
+}
+
+// Decoder reads NBT data from a buffered stream.
+type Decoder struct {
+	r    *bufio.Reader
+	buf  [8]byte
+	name []byte
+	err  error
+}
+
+// NewDecoder yields a Decoder reading from r. Input is buffered, so the
+// Decoder may read past the end of the data it decodes.
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{r: br}
+}
+
+// Err yields the first error the Decoder encountered, if any.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// fail records err, unless there's already an error.
+func (d *Decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// read reads exactly n bytes into the Decoder's buffer, yielding false
+// on failure, in which case the buffer is zeroed so the decoded value is
+// too.
+func (d *Decoder) read(n int) bool {
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, d.buf[:n])
+	}
+	if d.err != nil {
+		d.buf = [8]byte{}
+		return false
+	}
+	return true
+}
+
+// Header reads the type and name which precede a value in a Compound.
+// At the End tag, or on error, it yields TypeEnd. The name is only valid
+// until the next call to Header.
+func (d *Decoder) Header() (Type, []byte) {
+	if !d.read(1) {
+		return TypeEnd, nil
+	}
+	typ := Type(d.buf[0])
+	if typ == TypeEnd {
+		return typ, nil
+	}
+	n := int(uint16(d.Short()))
+	if cap(d.name) < n {
+		d.name = make([]byte, n)
+	}
+	d.name = d.name[:n]
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, d.name)
+	}
+	if d.err != nil {
+		return TypeEnd, nil
+	}
+	return typ, d.name
+}
+
+// Expect verifies that a value of type typ, stored under name, can be
+// decoded as type want. If not, it records an error, and yields false.
+func (d *Decoder) Expect(typ, want Type, name []byte) bool {
+	if typ != want {
+		d.fail(fmt.Errorf("%q: expected %v, found %v", name, want, typ))
+		return false
+	}
+	return d.err == nil
+}
+
+// Byte reads a Byte payload.
+func (d *Decoder) Byte() int8 {
+	d.read(1)
+	return int8(d.buf[0])
+}
+
+// Short reads a Short payload.
+func (d *Decoder) Short() int16 {
+	d.read(2)
+	return int16(binary.BigEndian.Uint16(d.buf[:2]))
+}
+
+// Int reads an Int payload.
+func (d *Decoder) Int() int32 {
+	d.read(4)
+	return int32(binary.BigEndian.Uint32(d.buf[:4]))
+}
+
+// Long reads a Long payload.
+func (d *Decoder) Long() int64 {
+	d.read(8)
+	return int64(binary.BigEndian.Uint64(d.buf[:8]))
+}
+
+// Float reads a Float payload.
+func (d *Decoder) Float() float32 {
+	return math.Float32frombits(uint32(d.Int()))
+}
+
+// Double reads a Double payload.
+func (d *Decoder) Double() float64 {
+	return math.Float64frombits(uint64(d.Long()))
+}
+
+// Text reads a String payload. It's not called String, because then
+// printing a Decoder would consume its input.
+func (d *Decoder) Text() string {
+	n := int(uint16(d.Short()))
+	if d.err != nil {
+		return ""
+	}
+	buf := make([]byte, n)
+	if _, d.err = io.ReadFull(d.r, buf); d.err != nil {
+		return ""
+	}
+	return unsafe.String(unsafe.SliceData(buf), n)
+}
+
+// count reads a length prefix for an array or list.
+func (d *Decoder) count() int {
+	n := d.Int()
+	if n < 0 {
+		d.fail(fmt.Errorf("invalid negative count: %d", n))
+		return 0
+	}
+	return int(n)
+}
+
+// ByteArray reads a ByteArray payload.
+func (d *Decoder) ByteArray() []int8 {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	buf := make([]byte, n)
+	if _, d.err = io.ReadFull(d.r, buf); d.err != nil {
+		return nil
+	}
+	return *(*[]int8)(unsafe.Pointer(&buf))
+}
+
+// IntArray reads an IntArray payload.
+func (d *Decoder) IntArray() []int32 {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	out := make([]int32, n)
+	for i := range out {
+		out[i] = d.Int()
+	}
+	return out
+}
+
+// LongArray reads a LongArray payload.
+func (d *Decoder) LongArray() []int64 {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	out := make([]int64, n)
+	for i := range out {
+		out[i] = d.Long()
+	}
+	return out
+}
+
+// ListHeader reads the element type and length which begin a List
+// payload, and yields the length, which is 0 on error. Elements must be
+// of type want, unless the list is empty. The caller then reads that many
+// payloads of that type.
+func (d *Decoder) ListHeader(want Type) int {
+	if !d.read(1) {
+		return 0
+	}
+	typ := Type(d.buf[0])
+	n := d.count()
+	if d.err != nil {
+		return 0
+	}
+	if n != 0 && typ != want {
+		d.fail(fmt.Errorf("expected list of %v, found list of %v", want, typ))
+		return 0
+	}
+	return n
+}
+
+// Tag reads the payload of an arbitrary tag of type typ.
+func (d *Decoder) Tag(typ Type) Tag {
+	if d.err != nil {
+		return nil
+	}
+	var t Tag
+	t, d.err = loadPayload(d.r, typ)
+	return t
+}
+
+// TagList reads a List payload of any kind, yielding its elements.
+func (d *Decoder) TagList() []Tag {
+	t := d.Tag(TypeList)
+	if d.err != nil {
+		return nil
+	}
+	return collectionElements(t)
+}
+
+// Skip reads past a payload of type typ, without decoding it.
+func (d *Decoder) Skip(typ Type) {
+	if d.err == nil {
+		d.err = skipPayload(d.r, typ)
+	}
+}
+
+// Unmarshal reads an uncompressed Compound from r into v, and yields its
+// name.
+func Unmarshal(r io.Reader, v Unmarshaler) (String, error) {
+	d := NewDecoder(r)
+	typ, name := d.Header()
+	if d.err != nil {
+		return "", d.err
+	}
+	if typ != TypeCompound {
+		return "", fmt.Errorf("expected Compound, found %v", typ)
+	}
+	out := String(name)
+	return out, v.UnmarshalNBT(d)
+}
@@ -0,0 +1,223 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Conversions between numeric types, and between lists and arrays of
+// numbers. Minecraft is fairly lenient about which numeric type it finds
+// where, so tools often need to be too.
+
+var (
+	NumericOverflow      = errors.New("value out of range")
+	NumericPrecisionLoss = errors.New("conversion would lose precision")
+	NotNumeric           = errors.New("not a numeric type")
+)
+
+// conversionError describes a failed conversion of t to the given type,
+// wrapping one of the sentinel errors above.
+func conversionError(t Tag, to interface{}, err error) error {
+	if TagHasElements(t) {
+		return fmt.Errorf("converting %v to %v: %w", t.Type(), to, err)
+	}
+	return fmt.Errorf("converting %v %s to %v: %w", t.Type(), FormatSNBT(t), to, err)
+}
+
+// twoTo63 is the smallest float64 which doesn't fit in an int64.
+const twoTo63 = float64(1 << 63)
+
+// AsInt64 yields the value of any numeric tag as an int64. Float and Double
+// values must be whole numbers within range.
+func AsInt64(t Tag) (int64, error) {
+	switch v := t.(type) {
+	case Byte:
+		return int64(v), nil
+	case Short:
+		return int64(v), nil
+	case Int:
+		return int64(v), nil
+	case Long:
+		return int64(v), nil
+	case Float, Double:
+		f, _ := AsFloat64(t)
+		if math.IsNaN(f) || math.IsInf(f, 0) || f != math.Trunc(f) {
+			return 0, conversionError(t, "int64", NumericPrecisionLoss)
+		}
+		if f < -twoTo63 || f >= twoTo63 {
+			return 0, conversionError(t, "int64", NumericOverflow)
+		}
+		return int64(f), nil
+	}
+	if t == nil {
+		return 0, NotNumeric
+	}
+	return 0, conversionError(t, "int64", NotNumeric)
+}
+
+// AsFloat64 yields the value of any numeric tag as a float64. Long values
+// too large to represent exactly are an error.
+func AsFloat64(t Tag) (float64, error) {
+	switch v := t.(type) {
+	case Byte:
+		return float64(v), nil
+	case Short:
+		return float64(v), nil
+	case Int:
+		return float64(v), nil
+	case Long:
+		f := float64(v)
+		if f >= twoTo63 || int64(f) != int64(v) {
+			return 0, conversionError(t, "float64", NumericPrecisionLoss)
+		}
+		return f, nil
+	case Float:
+		return float64(v), nil
+	case Double:
+		return float64(v), nil
+	}
+	if t == nil {
+		return 0, NotNumeric
+	}
+	return 0, conversionError(t, "float64", NotNumeric)
+}
+
+// AsBool yields whether a numeric tag is non-zero, which is how Minecraft
+// stores booleans (usually in a Byte).
+func AsBool(t Tag) (bool, error) {
+	switch v := t.(type) {
+	case Float:
+		return v != 0, nil
+	case Double:
+		return v != 0, nil
+	}
+	i, err := AsInt64(t)
+	if err != nil {
+		return false, err
+	}
+	return i != 0, nil
+}
+
+// ConvertTo converts t to the given type. Numeric types convert to each
+// other as long as the value is representable exactly in the new type.
+// Lists of numbers convert to and from the corresponding array types
+// (and arrays to other arrays), converting each element. Converting a tag
+// to its own type yields the tag unchanged.
+func ConvertTo(t Tag, typ Type) (Tag, error) {
+	if t == nil {
+		return nil, fmt.Errorf("can't convert nil tag")
+	}
+	if t.Type() == typ {
+		return t, nil
+	}
+	switch typ {
+	case TypeByte, TypeShort, TypeInt, TypeLong:
+		return convertInteger(t, typ)
+	case TypeFloat:
+		return convertFloat(t)
+	case TypeDouble:
+		f, err := AsFloat64(t)
+		if err != nil {
+			return nil, err
+		}
+		return Double(f), nil
+	case TypeByteArray, TypeIntArray, TypeLongArray:
+		return convertToArray(t, typ)
+	case TypeList:
+		if !tagIsIndexable(t) {
+			break
+		}
+		elems := collectionElements(t)
+		return makeListFromTags(arrayElementType(t.Type()), elems)
+	}
+	return nil, conversionError(t, typ, errors.New("unsupported conversion"))
+}
+
+func convertInteger(t Tag, typ Type) (Tag, error) {
+	i, err := AsInt64(t)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case TypeByte:
+		if i < math.MinInt8 || i > math.MaxInt8 {
+			return nil, conversionError(t, typ, NumericOverflow)
+		}
+		return Byte(i), nil
+	case TypeShort:
+		if i < math.MinInt16 || i > math.MaxInt16 {
+			return nil, conversionError(t, typ, NumericOverflow)
+		}
+		return Short(i), nil
+	case TypeInt:
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, conversionError(t, typ, NumericOverflow)
+		}
+		return Int(i), nil
+	default:
+		return Long(i), nil
+	}
+}
+
+func convertFloat(t Tag) (Tag, error) {
+	switch t.(type) {
+	case Byte, Short, Int, Long:
+		i, _ := AsInt64(t)
+		f := float32(i)
+		if float64(f) >= twoTo63 || int64(f) != i {
+			return nil, conversionError(t, TypeFloat, NumericPrecisionLoss)
+		}
+		return Float(f), nil
+	}
+	d, err := AsFloat64(t)
+	if err != nil {
+		return nil, err
+	}
+	f := float32(d)
+	if math.IsInf(float64(f), 0) && !math.IsInf(d, 0) {
+		return nil, conversionError(t, TypeFloat, NumericOverflow)
+	}
+	if float64(f) != d && !math.IsNaN(d) {
+		return nil, conversionError(t, TypeFloat, NumericPrecisionLoss)
+	}
+	return Float(f), nil
+}
+
+// arrayElementType yields the type of the elements of an array type.
+func arrayElementType(typ Type) Type {
+	switch typ {
+	case TypeByteArray:
+		return TypeByte
+	case TypeIntArray:
+		return TypeInt
+	case TypeLongArray:
+		return TypeLong
+	}
+	return TypeEnd
+}
+
+func convertToArray(t Tag, typ Type) (Tag, error) {
+	if !tagIsIndexable(t) {
+		return nil, conversionError(t, typ, errors.New("unsupported conversion"))
+	}
+	elemType := arrayElementType(typ)
+	elems := collectionElements(t)
+	for i, e := range elems {
+		conv, err := ConvertTo(e, elemType)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		elems[i] = conv
+	}
+	var empty Tag
+	switch typ {
+	case TypeByteArray:
+		empty = ByteArray{}
+	case TypeIntArray:
+		empty = IntArray{}
+	default:
+		empty = LongArray{}
+	}
+	return rebuildCollection(empty, elems)
+}
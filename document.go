@@ -0,0 +1,208 @@
+package nbt
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Persistent documents. A Document is an immutable tree of tags; editing
+// it yields a new Document which shares everything but the path to the
+// edited node with the old one. Since nothing in a Document is ever
+// modified, any number of goroutines can read one without locking, and
+// snapshotting one is just keeping a pointer to it.
+
+// Document is an immutable tree of tags. The zero value isn't valid; use
+// NewDocument.
+type Document struct {
+	root Tag
+}
+
+// NewDocument makes a Document holding a copy of t, so later changes to
+// t don't affect it. Anything loaded lazily is decoded first.
+func NewDocument(t Tag) (*Document, error) {
+	if t == nil {
+		return nil, fmt.Errorf("can't make document from nil tag")
+	}
+	root, err := Decode(TagCopy(t))
+	if err != nil {
+		return nil, err
+	}
+	return &Document{root: root}, nil
+}
+
+// Root yields the document's root tag, which shares storage with the
+// document, and must not be modified. It can be used with Get, Walk, and
+// other functions which only read tags.
+func (d *Document) Root() Tag {
+	return d.root
+}
+
+// Tag yields a copy of the document's tree, which can be modified freely.
+func (d *Document) Tag() Tag {
+	return TagCopy(d.root)
+}
+
+// Lookup yields the tag found by following path from the root, using the
+// same path components as Get. The result must not be modified.
+func (d *Document) Lookup(path ...interface{}) (Tag, error) {
+	return Get[Tag](d.root, path...)
+}
+
+// Set yields a new document in which the tag at path is a copy of value.
+// The last component may name a new Compound entry, but List and array
+// elements have to exist already, and keep their types. As with
+// NewDocument, anything in value which was loaded lazily is decoded.
+func (d *Document) Set(value Tag, path ...interface{}) (*Document, error) {
+	if value == nil {
+		return nil, fmt.Errorf("can't set nil tag")
+	}
+	value, err := Decode(TagCopy(value))
+	if err != nil {
+		return nil, err
+	}
+	return d.update(path, func(Tag, bool) (Tag, error) {
+		return value, nil
+	})
+}
+
+// Delete yields a new document without the tag at path.
+func (d *Document) Delete(path ...interface{}) (*Document, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("can't delete document root")
+	}
+	return d.update(path, func(_ Tag, exists bool) (Tag, error) {
+		if !exists {
+			return nil, &AccessError{Path: path[:len(path)-1], Component: path[len(path)-1], Got: TypeCompound, Missing: true}
+		}
+		return nil, nil
+	})
+}
+
+// docUpdate yields the replacement for a tag in a document, or nil to
+// delete it.
+type docUpdate func(old Tag, exists bool) (Tag, error)
+
+// update applies fn at path, copying every container on the way to it.
+func (d *Document) update(path []interface{}, fn docUpdate) (*Document, error) {
+	root, err := updateCopy(d.root, path, 0, fn)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("can't delete document root")
+	}
+	return &Document{root: root}, nil
+}
+
+// updateCopy yields a copy of t in which fn has been applied to the tag
+// found by following path[depth:]. Only the containers along the path are
+// copied; everything else is shared.
+func updateCopy(t Tag, path []interface{}, depth int, fn docUpdate) (Tag, error) {
+	if depth == len(path) {
+		return fn(t, true)
+	}
+	comp := path[depth]
+	if c, ok := t.(Compound); ok {
+		key, ok := componentKey(comp)
+		if !ok {
+			return nil, &AccessError{Path: path[:depth], Component: comp, Got: TypeCompound}
+		}
+		child, exists := c[key]
+		if depth == len(path)-1 {
+			next, err := fn(child, exists)
+			if err != nil {
+				return nil, err
+			}
+			return copyCompoundWith(c, key, next), nil
+		}
+		if !exists {
+			return nil, &AccessError{Path: path[:depth], Component: comp, Got: TypeCompound, Missing: true}
+		}
+		next, err := updateCopy(child, path, depth+1, fn)
+		if err != nil {
+			return nil, err
+		}
+		return copyCompoundWith(c, key, next), nil
+	}
+	i, ok := tagIndex(comp)
+	if !ok || !tagIsIndexable(t) || i < 0 || i >= TagLength(t) {
+		e := &AccessError{Path: path[:depth], Component: comp}
+		if t != nil {
+			e.Got = t.Type()
+		}
+		e.Missing = ok && tagIsIndexable(t)
+		return nil, e
+	}
+	elems := collectionElements(t)
+	next, err := updateCopy(elems[i], path, depth+1, fn)
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		elems = append(elems[:i], elems[i+1:]...)
+	} else {
+		elems[i] = next
+	}
+	return rebuildCollection(t, elems)
+}
+
+// componentKey yields the Compound key comp represents.
+func componentKey(comp interface{}) (String, bool) {
+	switch k := comp.(type) {
+	case String:
+		return k, true
+	case string:
+		return String(k), true
+	}
+	return "", false
+}
+
+// copyCompoundWith yields a copy of c in which key holds t, or is deleted
+// if t is nil.
+func copyCompoundWith(c Compound, key String, t Tag) Compound {
+	out := make(Compound, len(c)+1)
+	for k, v := range c {
+		out[k] = v
+	}
+	if t == nil {
+		delete(out, key)
+	} else {
+		out[key] = t
+	}
+	return out
+}
+
+// SharedDocument holds the current version of a Document, which any
+// number of goroutines can read while others replace it. The zero value
+// holds no document.
+type SharedDocument struct {
+	current atomic.Pointer[Document]
+}
+
+// Load yields the current version of the document, which stays valid and
+// unchanged regardless of later updates.
+func (s *SharedDocument) Load() *Document {
+	return s.current.Load()
+}
+
+// Store replaces the current version of the document.
+func (s *SharedDocument) Store(d *Document) {
+	s.current.Store(d)
+}
+
+// Update replaces the current version of the document with fn's result.
+// If another goroutine replaces it first, fn is called again with the
+// new version, so it shouldn't have other side effects. If fn fails, the
+// document is left alone.
+func (s *SharedDocument) Update(fn func(*Document) (*Document, error)) error {
+	for {
+		old := s.current.Load()
+		d, err := fn(old)
+		if err != nil {
+			return err
+		}
+		if s.current.CompareAndSwap(old, d) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Package bigtest is an example of generated NBT methods, for the
+// structure of the bigtest.nbt example file. The types started as the
+// output of
+//
+//	nbt2go -package bigtest -type Level ../bigtest.nbt
+//
+// and were then edited by hand: the byte array's field name, which nbt2go
+// makes from the whole key, was shortened to ByteArrayTest; the list
+// element type ListTestCompoundElem was renamed Named; the identical Egg
+// and Ham types were merged into Food; and doc comments were added.
+package bigtest
+
+//go:generate go run ../../cmd/nbtgen -in bigtest.go -out bigtest_nbt.go
+
+// Level is the top-level compound.
+type Level struct {
+	ByteArrayTest      []int8             `nbt:"byteArrayTest (the first 1000 values of (n*n*255+n*7)%100, starting with n=0 (0, 62, 34, 16, 8, ...))"`
+	ByteTest           int8               `nbt:"byteTest"`
+	DoubleTest         float64            `nbt:"doubleTest"`
+	FloatTest          float32            `nbt:"floatTest"`
+	IntTest            int32              `nbt:"intTest"`
+	ListTestCompound   []Named            `nbt:"listTest (compound)"`
+	ListTestLong       []int64            `nbt:"listTest (long),list"`
+	LongTest           int64              `nbt:"longTest"`
+	NestedCompoundTest NestedCompoundTest `nbt:"nested compound test"`
+	ShortTest          int16              `nbt:"shortTest"`
+	StringTest         string             `nbt:"stringTest"`
+}
+
+// Named is an element of the compound list.
+type Named struct {
+	CreatedOn int64  `nbt:"created-on"`
+	Name      string `nbt:"name"`
+}
+
+// NestedCompoundTest holds the nested compounds.
+type NestedCompoundTest struct {
+	Egg Food `nbt:"egg"`
+	Ham Food `nbt:"ham"`
+}
+
+// Food is one of the nested compounds.
+type Food struct {
+	Name  string  `nbt:"name"`
+	Value float32 `nbt:"value"`
+}
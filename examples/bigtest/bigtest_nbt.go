@@ -0,0 +1,207 @@
+// Code generated by nbtgen from bigtest.go; DO NOT EDIT.
+
+package bigtest
+
+import "github.com/seebs/nbt"
+
+// MarshalNBT writes x as the payload of a Compound.
+func (x *Level) MarshalNBT(e *nbt.Encoder) error {
+	e.Header(nbt.TypeByteArray, "byteArrayTest (the first 1000 values of (n*n*255+n*7)%100, starting with n=0 (0, 62, 34, 16, 8, ...))")
+	e.ByteArray(x.ByteArrayTest)
+	e.Header(nbt.TypeByte, "byteTest")
+	e.Byte(x.ByteTest)
+	e.Header(nbt.TypeDouble, "doubleTest")
+	e.Double(x.DoubleTest)
+	e.Header(nbt.TypeFloat, "floatTest")
+	e.Float(x.FloatTest)
+	e.Header(nbt.TypeInt, "intTest")
+	e.Int(x.IntTest)
+	e.Header(nbt.TypeList, "listTest (compound)")
+	e.ListHeader(nbt.TypeCompound, len(x.ListTestCompound))
+	for i0 := range x.ListTestCompound {
+		x.ListTestCompound[i0].MarshalNBT(e)
+	}
+	e.Header(nbt.TypeList, "listTest (long)")
+	e.ListHeader(nbt.TypeLong, len(x.ListTestLong))
+	for i0 := range x.ListTestLong {
+		e.Long(x.ListTestLong[i0])
+	}
+	e.Header(nbt.TypeLong, "longTest")
+	e.Long(x.LongTest)
+	e.Header(nbt.TypeCompound, "nested compound test")
+	x.NestedCompoundTest.MarshalNBT(e)
+	e.Header(nbt.TypeShort, "shortTest")
+	e.Short(x.ShortTest)
+	e.Header(nbt.TypeString, "stringTest")
+	e.Text(x.StringTest)
+	e.End()
+	return e.Err()
+}
+
+// UnmarshalNBT reads x from the payload of a Compound. Entries with
+// unknown keys are skipped.
+func (x *Level) UnmarshalNBT(d *nbt.Decoder) error {
+	for {
+		typ, name := d.Header()
+		if typ == nbt.TypeEnd {
+			break
+		}
+		switch string(name) {
+		case "byteArrayTest (the first 1000 values of (n*n*255+n*7)%100, starting with n=0 (0, 62, 34, 16, 8, ...))":
+			if d.Expect(typ, nbt.TypeByteArray, name) {
+				x.ByteArrayTest = d.ByteArray()
+			}
+		case "byteTest":
+			if d.Expect(typ, nbt.TypeByte, name) {
+				x.ByteTest = d.Byte()
+			}
+		case "doubleTest":
+			if d.Expect(typ, nbt.TypeDouble, name) {
+				x.DoubleTest = d.Double()
+			}
+		case "floatTest":
+			if d.Expect(typ, nbt.TypeFloat, name) {
+				x.FloatTest = d.Float()
+			}
+		case "intTest":
+			if d.Expect(typ, nbt.TypeInt, name) {
+				x.IntTest = d.Int()
+			}
+		case "listTest (compound)":
+			if d.Expect(typ, nbt.TypeList, name) {
+				x.ListTestCompound = make([]Named, d.ListHeader(nbt.TypeCompound))
+				for i0 := range x.ListTestCompound {
+					x.ListTestCompound[i0].UnmarshalNBT(d)
+				}
+			}
+		case "listTest (long)":
+			if d.Expect(typ, nbt.TypeList, name) {
+				x.ListTestLong = make([]int64, d.ListHeader(nbt.TypeLong))
+				for i0 := range x.ListTestLong {
+					x.ListTestLong[i0] = d.Long()
+				}
+			}
+		case "longTest":
+			if d.Expect(typ, nbt.TypeLong, name) {
+				x.LongTest = d.Long()
+			}
+		case "nested compound test":
+			if d.Expect(typ, nbt.TypeCompound, name) {
+				x.NestedCompoundTest.UnmarshalNBT(d)
+			}
+		case "shortTest":
+			if d.Expect(typ, nbt.TypeShort, name) {
+				x.ShortTest = d.Short()
+			}
+		case "stringTest":
+			if d.Expect(typ, nbt.TypeString, name) {
+				x.StringTest = d.Text()
+			}
+		default:
+			d.Skip(typ)
+		}
+	}
+	return d.Err()
+}
+
+// MarshalNBT writes x as the payload of a Compound.
+func (x *Named) MarshalNBT(e *nbt.Encoder) error {
+	e.Header(nbt.TypeLong, "created-on")
+	e.Long(x.CreatedOn)
+	e.Header(nbt.TypeString, "name")
+	e.Text(x.Name)
+	e.End()
+	return e.Err()
+}
+
+// UnmarshalNBT reads x from the payload of a Compound. Entries with
+// unknown keys are skipped.
+func (x *Named) UnmarshalNBT(d *nbt.Decoder) error {
+	for {
+		typ, name := d.Header()
+		if typ == nbt.TypeEnd {
+			break
+		}
+		switch string(name) {
+		case "created-on":
+			if d.Expect(typ, nbt.TypeLong, name) {
+				x.CreatedOn = d.Long()
+			}
+		case "name":
+			if d.Expect(typ, nbt.TypeString, name) {
+				x.Name = d.Text()
+			}
+		default:
+			d.Skip(typ)
+		}
+	}
+	return d.Err()
+}
+
+// MarshalNBT writes x as the payload of a Compound.
+func (x *NestedCompoundTest) MarshalNBT(e *nbt.Encoder) error {
+	e.Header(nbt.TypeCompound, "egg")
+	x.Egg.MarshalNBT(e)
+	e.Header(nbt.TypeCompound, "ham")
+	x.Ham.MarshalNBT(e)
+	e.End()
+	return e.Err()
+}
+
+// UnmarshalNBT reads x from the payload of a Compound. Entries with
+// unknown keys are skipped.
+func (x *NestedCompoundTest) UnmarshalNBT(d *nbt.Decoder) error {
+	for {
+		typ, name := d.Header()
+		if typ == nbt.TypeEnd {
+			break
+		}
+		switch string(name) {
+		case "egg":
+			if d.Expect(typ, nbt.TypeCompound, name) {
+				x.Egg.UnmarshalNBT(d)
+			}
+		case "ham":
+			if d.Expect(typ, nbt.TypeCompound, name) {
+				x.Ham.UnmarshalNBT(d)
+			}
+		default:
+			d.Skip(typ)
+		}
+	}
+	return d.Err()
+}
+
+// MarshalNBT writes x as the payload of a Compound.
+func (x *Food) MarshalNBT(e *nbt.Encoder) error {
+	e.Header(nbt.TypeString, "name")
+	e.Text(x.Name)
+	e.Header(nbt.TypeFloat, "value")
+	e.Float(x.Value)
+	e.End()
+	return e.Err()
+}
+
+// UnmarshalNBT reads x from the payload of a Compound. Entries with
+// unknown keys are skipped.
+func (x *Food) UnmarshalNBT(d *nbt.Decoder) error {
+	for {
+		typ, name := d.Header()
+		if typ == nbt.TypeEnd {
+			break
+		}
+		switch string(name) {
+		case "name":
+			if d.Expect(typ, nbt.TypeString, name) {
+				x.Name = d.Text()
+			}
+		case "value":
+			if d.Expect(typ, nbt.TypeFloat, name) {
+				x.Value = d.Float()
+			}
+		default:
+			d.Skip(typ)
+		}
+	}
+	return d.Err()
+}
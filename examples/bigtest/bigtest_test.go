@@ -0,0 +1,109 @@
+package bigtest
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/seebs/nbt"
+)
+
+// uncompressed yields the uncompressed contents of bigtest.nbt.
+func uncompressed(tb testing.TB) []byte {
+	f, err := os.Open("../bigtest.nbt")
+	if err != nil {
+		tb.Fatalf("opening bigtest: %s", err)
+	}
+	defer f.Close()
+	t, name, err := nbt.Load(f)
+	if err != nil {
+		tb.Fatalf("loading bigtest: %s", err)
+	}
+	buf := &bytes.Buffer{}
+	if err = nbt.StoreUncompressed(buf, t, name); err != nil {
+		tb.Fatalf("storing bigtest: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := uncompressed(t)
+	var l Level
+	name, err := nbt.Unmarshal(bytes.NewReader(data), &l)
+	if err != nil {
+		t.Fatalf("unmarshal: %s", err)
+	}
+	if name != "Level" {
+		t.Errorf("name: expected Level, got %q", name)
+	}
+	if l.NestedCompoundTest.Egg.Name != "Eggbert" || len(l.ListTestCompound) != 2 || len(l.ByteArrayTest) != 1000 {
+		t.Errorf("unexpected contents: %+v", l.NestedCompoundTest)
+	}
+	buf := &bytes.Buffer{}
+	if err = nbt.Marshal(buf, &l, name); err != nil {
+		t.Fatalf("marshal: %s", err)
+	}
+	if buf.Len() != len(data) {
+		t.Errorf("size: expected %d bytes, got %d", len(data), buf.Len())
+	}
+	orig, _, _ := nbt.LoadUncompressed(bytes.NewReader(data))
+	got, _, err := nbt.LoadUncompressed(buf)
+	if err != nil {
+		t.Fatalf("reloading: %s", err)
+	}
+	if !nbt.TagEqual(orig, got) {
+		t.Errorf("round trip changed contents:\n%+v\n%+v", orig, got)
+	}
+}
+
+func TestTypeMismatch(t *testing.T) {
+	buf := &bytes.Buffer{}
+	nbt.StoreUncompressed(buf, nbt.Compound{"intTest": nbt.String("x")}, "")
+	var l Level
+	if _, err := nbt.Unmarshal(buf, &l); err == nil {
+		t.Errorf("expected error decoding String into int32 field")
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data := uncompressed(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var l Level
+		if _, err := nbt.Unmarshal(bytes.NewReader(data), &l); err != nil {
+			b.Fatalf("unmarshal: %s", err)
+		}
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	data := uncompressed(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := nbt.LoadUncompressed(bytes.NewReader(data)); err != nil {
+			b.Fatalf("load: %s", err)
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	var l Level
+	nbt.Unmarshal(bytes.NewReader(uncompressed(b)), &l)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := nbt.Marshal(io.Discard, &l, "Level"); err != nil {
+			b.Fatalf("marshal: %s", err)
+		}
+	}
+}
+
+func BenchmarkStore(b *testing.B) {
+	t, name, _ := nbt.LoadUncompressed(bytes.NewReader(uncompressed(b)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := nbt.StoreUncompressed(io.Discard, t, name); err != nil {
+			b.Fatalf("store: %s", err)
+		}
+	}
+}
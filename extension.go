@@ -0,0 +1,187 @@
+package nbt
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Support for tag types beyond the ones Minecraft defines, such as those
+// used by mods. A custom tag type is a Go type implementing Tag, whose
+// Type method returns a type ID of TypeMax or higher, plus a TagLoader
+// registered for that ID. Lists of custom types work too.
+
+// TagLoader loads the payload of a tag of a custom type.
+type TagLoader func(r io.Reader) (Tag, error)
+
+var extensions struct {
+	sync.RWMutex
+	loaders map[Type]TagLoader
+}
+
+// RegisterTagType registers a loader for the custom tag type typ, which
+// must not be one of the built-in types. A type can only be registered
+// once. This is usually done from an init function.
+func RegisterTagType(typ Type, load TagLoader) error {
+	if typ < TypeMax {
+		return fmt.Errorf("can't register built-in type %v", typ)
+	}
+	if load == nil {
+		return fmt.Errorf("can't register nil loader for type %v", typ)
+	}
+	extensions.Lock()
+	defer extensions.Unlock()
+	if extensions.loaders == nil {
+		extensions.loaders = make(map[Type]TagLoader)
+	}
+	if _, ok := extensions.loaders[typ]; ok {
+		return fmt.Errorf("type %v already registered", typ)
+	}
+	extensions.loaders[typ] = load
+	return nil
+}
+
+// tagLoader yields the registered loader for typ, or nil.
+func tagLoader(typ Type) TagLoader {
+	extensions.RLock()
+	defer extensions.RUnlock()
+	return extensions.loaders[typ]
+}
+
+// validType indicates whether typ is a built-in or registered type.
+func validType(typ Type) bool {
+	return typ < TypeMax || tagLoader(typ) != nil
+}
+
+// loadExtension loads a payload of a custom type.
+func loadExtension(r io.Reader, typ Type) (Tag, error) {
+	load := tagLoader(typ)
+	if load == nil {
+		return nil, fmt.Errorf("unsupported tag type %v", typ)
+	}
+	t, err := load(r)
+	if err != nil {
+		return t, err
+	}
+	if t == nil || t.Type() != typ {
+		return nil, fmt.Errorf("loader for type %v returned wrong tag type", typ)
+	}
+	return t, nil
+}
+
+// RawTag holds the undecoded payload of a tag of a custom type, so it can
+// be written back out unchanged.
+type RawTag struct {
+	Typ  Type
+	Data []byte
+}
+
+// Type yields the tag's custom type.
+func (r RawTag) Type() Type {
+	return r.Typ
+}
+
+// Store writes the payload unchanged.
+func (r RawTag) Store(w io.Writer) error {
+	_, err := w.Write(r.Data)
+	return err
+}
+
+// FixedSizeLoader yields a TagLoader for a custom type whose payloads are
+// always n bytes long, which loads them as RawTags. Payloads of variable
+// size need a loader which understands their format.
+func FixedSizeLoader(typ Type, n int) TagLoader {
+	return func(r io.Reader) (Tag, error) {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		if err != nil {
+			return nil, err
+		}
+		return RawTag{Typ: typ, Data: buf}, nil
+	}
+}
+
+// extensionList is the listData for lists of a custom type, which holds
+// the elements as Tags since there's no concrete type to use.
+type extensionList struct {
+	typ   Type
+	elems []Tag
+}
+
+func (el extensionList) length() int {
+	return len(el.elems)
+}
+
+func (el extensionList) element(i int) Tag {
+	return el.elems[i]
+}
+
+func (el extensionList) iterate(fn func(int, Tag) error) error {
+	for i, v := range el.elems {
+		if err := fn(i, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (el extensionList) appendData(dst []byte) ([]byte, error) {
+	var err error
+	for _, v := range el.elems {
+		if dst, err = appendPayload(dst, v); err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+func (el extensionList) set(i int, t Tag) error {
+	if t.Type() != el.typ {
+		return ListTypeError{Contents: el.typ, Got: t.Type()}
+	}
+	el.elems[i] = t
+	return nil
+}
+
+func (el extensionList) insert(i int, t Tag) (listData, error) {
+	if t.Type() != el.typ {
+		return el, ListTypeError{Contents: el.typ, Got: t.Type()}
+	}
+	el.elems = append(el.elems, nil)
+	copy(el.elems[i+1:], el.elems[i:])
+	el.elems[i] = t
+	return el, nil
+}
+
+func (el extensionList) remove(i int) listData {
+	el.elems = append(el.elems[:i], el.elems[i+1:]...)
+	return el
+}
+
+// extensionListKind yields the listKind for a registered custom type.
+func extensionListKind(typ Type) *listKind {
+	if tagLoader(typ) == nil {
+		return nil
+	}
+	return &listKind{
+		fromTags: func(in []Tag) (listData, error) {
+			for _, t := range in {
+				if t.Type() != typ {
+					return nil, ListTypeError{Contents: typ, Got: t.Type()}
+				}
+			}
+			return extensionList{typ: typ, elems: append([]Tag{}, in...)}, nil
+		},
+		load: func(r io.Reader, count int) (listData, error) {
+			out := extensionList{typ: typ, elems: make([]Tag, 0, count)}
+			for i := 0; i < count; i++ {
+				t, err := loadExtension(r, typ)
+				if err != nil {
+					return out, err
+				}
+				out.elems = append(out.elems, t)
+			}
+			return out, nil
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package nbt
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Support for fmt. Every tag type implements fmt.Formatter (see the Format
+// methods in typegen.go), all of them in terms of formatTag:
+//
+//	%v   a short summary, which is the tag's String()
+//	%+v  the tag as SNBT
+//	%#v  Go source which constructs the tag
+//
+// %s and %q format String(), and other verbs format the underlying Go
+// value, so %x of an Int works as it would for an int32.
+
+// formatTag implements fmt.Formatter for t.
+func formatTag(f fmt.State, verb rune, t Tag) {
+	switch verb {
+	case 'v':
+		switch {
+		case f.Flag('#'):
+			io.WriteString(f, GoSyntax(t))
+		case f.Flag('+'):
+			io.WriteString(f, FormatSNBT(t))
+		default:
+			fmt.Fprintf(f, fmt.FormatString(f, 's'), tagString(t))
+		}
+	case 's', 'q':
+		fmt.Fprintf(f, fmt.FormatString(f, verb), tagString(t))
+	default:
+		fmt.Fprintf(f, fmt.FormatString(f, verb), rawValue(t))
+	}
+}
+
+// tagString yields t's String(), for tags which have one.
+func tagString(t Tag) string {
+	if s, ok := t.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return t.Type().String()
+}
+
+// rawValue yields the plain Go value underlying t, which has no Format
+// method, so it can be formatted with other verbs without recursing.
+func rawValue(t Tag) interface{} {
+	switch x := t.(type) {
+	case Byte:
+		return int8(x)
+	case Short:
+		return int16(x)
+	case Int:
+		return int32(x)
+	case Long:
+		return int64(x)
+	case Float:
+		return float32(x)
+	case Double:
+		return float64(x)
+	case String:
+		return string(x)
+	case ByteArray:
+		return []int8(x)
+	case IntArray:
+		out := make([]int32, len(x))
+		for i, v := range x {
+			out[i] = int32(v)
+		}
+		return out
+	case LongArray:
+		out := make([]int64, len(x))
+		for i, v := range x {
+			out[i] = int64(v)
+		}
+		return out
+	}
+	return tagString(t)
+}
+
+// GoSyntax yields Go source for an expression which constructs t, such as
+// `nbt.Compound{"x": nbt.Int(1)}`, for use in generated code or tests.
+// Compound keys are in sorted order, so the output is stable.
+func GoSyntax(t Tag) string {
+	buf := &strings.Builder{}
+	writeGo(buf, t)
+	return buf.String()
+}
+
+// goFloat formats a float as a Go expression.
+func goFloat(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "math.NaN()"
+	case math.IsInf(f, 1):
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
+
+func writeGo(buf *strings.Builder, t Tag) {
+	switch x := t.(type) {
+	case End:
+		buf.WriteString("nbt.End{}")
+	case Byte, Short, Int, Long:
+		fmt.Fprintf(buf, "nbt.%v(%d)", t.Type(), rawValue(t))
+	case Float:
+		fmt.Fprintf(buf, "nbt.Float(%s)", goFloat(float64(x), 32))
+	case Double:
+		fmt.Fprintf(buf, "nbt.Double(%s)", goFloat(float64(x), 64))
+	case String:
+		fmt.Fprintf(buf, "nbt.String(%s)", strconv.Quote(string(x)))
+	case ByteArray, IntArray, LongArray:
+		fmt.Fprintf(buf, "nbt.%v{", t.Type())
+		for i := 0; i < TagLength(t); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			elt, _ := TagElement(t, i)
+			fmt.Fprintf(buf, "%d", rawValue(elt))
+		}
+		buf.WriteByte('}')
+	case List:
+		elems := collectionElements(x)
+		switch {
+		case len(elems) == 0:
+			fmt.Fprintf(buf, "nbt.List{Contents: nbt.Type%v}", x.Contents)
+			return
+		case x.Mixed():
+			buf.WriteString("nbt.MustMakeMixedList([]nbt.Tag{")
+		case x.Contents >= TypeMax:
+			fmt.Fprintf(buf, "nbt.NewList([]%T{", elems[0])
+		default:
+			fmt.Fprintf(buf, "nbt.Make%vList([]nbt.%v{", x.Contents, x.Contents)
+		}
+		for i, e := range elems {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeGo(buf, e)
+		}
+		buf.WriteString("})")
+	case Compound:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		buf.WriteString("nbt.Compound{")
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			v, _ := x.entry(String(k))
+			fmt.Fprintf(buf, "%s: ", strconv.Quote(k))
+			writeGo(buf, v)
+		}
+		buf.WriteByte('}')
+	default:
+		// custom types are on their own
+		fmt.Fprintf(buf, "%#v", t)
+	}
+}
@@ -0,0 +1,229 @@
+package nbt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Byte-level indexes of NBT data, for finding where things are in a file
+// and for loading parts of it without reading the rest. Offsets are always
+// into the uncompressed data.
+
+// IndexEntry describes where a single tag is stored. List elements have
+// no header, just a payload.
+type IndexEntry struct {
+	Components []PathComponent
+	Type       Type
+	Offset     int64
+	HeaderLen  int64
+	PayloadLen int64
+}
+
+// PayloadOffset yields the offset of the entry's payload.
+func (e IndexEntry) PayloadOffset() int64 {
+	return e.Offset + e.HeaderLen
+}
+
+// Index holds an entry for every tag in a document, in the order they're
+// stored. Array elements aren't tags, and don't get entries.
+type Index struct {
+	Entries []IndexEntry
+	byPath  map[string]int
+}
+
+// indexKey yields a map key for a list of path components.
+func indexKey(comps []PathComponent) string {
+	buf := &strings.Builder{}
+	for _, c := range comps {
+		switch c := c.(type) {
+		case Int:
+			fmt.Fprintf(buf, "[%d]", c)
+		case String:
+			fmt.Fprintf(buf, "%q", c)
+		}
+	}
+	return buf.String()
+}
+
+// Lookup yields the entry for the tag reached by following comps from
+// the root.
+func (ix *Index) Lookup(comps ...PathComponent) (IndexEntry, bool) {
+	i, ok := ix.byPath[indexKey(comps)]
+	if !ok {
+		return IndexEntry{}, false
+	}
+	return ix.Entries[i], true
+}
+
+// Find yields the entry for the tag p leads to.
+func (ix *Index) Find(p Path) (IndexEntry, bool) {
+	return ix.Lookup(p.Components...)
+}
+
+// indexer scans a stream, counting bytes and recording entries. Unless
+// keepWrapped is set, the wrapped elements of mixed lists are recorded
+// without the wrapper, as loading unwraps them.
+type indexer struct {
+	r           io.Reader
+	pos         int64
+	ix          *Index
+	path        []PathComponent
+	keepWrapped bool
+}
+
+func (x *indexer) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	x.pos += int64(n)
+	return n, err
+}
+
+// tag indexes a tag whose payload starts at the current position.
+func (x *indexer) tag(typ Type, start int64) error {
+	e := IndexEntry{
+		Components: append([]PathComponent{}, x.path...),
+		Type:       typ,
+		Offset:     start,
+		HeaderLen:  x.pos - start,
+	}
+	i := len(x.ix.Entries)
+	x.ix.Entries = append(x.ix.Entries, e)
+	x.ix.byPath[indexKey(e.Components)] = i
+	err := x.payload(typ)
+	x.ix.Entries[i].PayloadLen = x.pos - e.PayloadOffset()
+	return err
+}
+
+func (x *indexer) payload(typ Type) error {
+	switch typ {
+	case TypeCompound:
+		for {
+			start := x.pos
+			typ, name, err := loadHeader(x)
+			if err == io.EOF {
+				return fmt.Errorf("unterminated compound tag")
+			}
+			if err != nil || typ == TypeEnd {
+				return err
+			}
+			x.path = append(x.path, name)
+			err = x.tag(typ, start)
+			x.path = x.path[:len(x.path)-1]
+			if err != nil {
+				return err
+			}
+		}
+	case TypeList:
+		elemType, err := loadByte(x)
+		if err != nil {
+			return err
+		}
+		count, err := loadInt(x)
+		if err != nil {
+			return err
+		}
+		if count < 0 {
+			return fmt.Errorf("invalid negative count for list: %d", count)
+		}
+		for i := 0; i < int(count); i++ {
+			x.path = append(x.path, Int(i))
+			entry := len(x.ix.Entries)
+			err = x.tag(Type(elemType), x.pos)
+			x.path = x.path[:len(x.path)-1]
+			if err != nil {
+				return err
+			}
+			if Type(elemType) == TypeCompound && !x.keepWrapped {
+				x.unwrap(entry)
+			}
+		}
+		return nil
+	}
+	return skipPayload(x, typ)
+}
+
+// unwrap checks whether the list element recorded at entry i is a
+// wrapped element of a mixed list, a compound holding only an entry under
+// "", and if so, replaces it with that entry, dropping the "" from the
+// paths of everything inside it.
+func (x *indexer) unwrap(i int) {
+	entries := x.ix.Entries
+	depth := len(entries[i].Components)
+	children := 0
+	for _, e := range entries[i+1:] {
+		if len(e.Components) == depth+1 {
+			children++
+		}
+	}
+	if children != 1 || entries[i+1].Components[depth] != String("") {
+		return
+	}
+	for _, e := range entries[i:] {
+		delete(x.ix.byPath, indexKey(e.Components))
+	}
+	entries = append(entries[:i], entries[i+1:]...)
+	for j := i; j < len(entries); j++ {
+		c := entries[j].Components
+		entries[j].Components = append(c[:depth:depth], c[depth+1:]...)
+		x.ix.byPath[indexKey(entries[j].Components)] = j
+	}
+	x.ix.Entries = entries
+}
+
+// BuildIndex indexes the first tag in the uncompressed stream r, without
+// decoding it. If the data is corrupt, the index covers everything up to
+// the problem, and the entries containing it have lengths which stop there.
+// Elements of mixed lists are indexed as loading unwraps them.
+func BuildIndex(r io.Reader) (*Index, error) {
+	return buildIndex(r, false)
+}
+
+// buildIndex indexes r, leaving mixed lists wrapped if keepWrapped is set.
+func buildIndex(r io.Reader, keepWrapped bool) (*Index, error) {
+	x := &indexer{r: bufio.NewReader(r), ix: &Index{byPath: make(map[string]int)}, keepWrapped: keepWrapped}
+	typ, _, err := loadHeader(x)
+	if err != nil || typ == TypeEnd {
+		return x.ix, err
+	}
+	return x.ix, x.tag(typ, 0)
+}
+
+// LoadIndexed is like the LoadIndexed function, using the options in o.
+func (o LoadOptions) LoadIndexed(r io.Reader) (Tag, String, *Index, error) {
+	src, err := decompress(r)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	ix, err := buildIndex(bytes.NewReader(data), o.KeepWrappedLists)
+	if err != nil {
+		return nil, "", ix, err
+	}
+	t, name, err := o.LoadUncompressed(bytes.NewReader(data))
+	return t, name, ix, err
+}
+
+// LoadIndexed loads the first tag from r, which may be compressed, like
+// Load, and also yields an index of the uncompressed data. It reads all
+// of the data into memory first.
+func LoadIndexed(r io.Reader) (Tag, String, *Index, error) {
+	return LoadOptions{}.LoadIndexed(r)
+}
+
+// LoadEntry loads the tag described by e from ra, which holds the same
+// uncompressed data e was indexed from, without reading anything else.
+func (o LoadOptions) LoadEntry(ra io.ReaderAt, e IndexEntry) (Tag, error) {
+	sr := io.NewSectionReader(ra, e.PayloadOffset(), e.PayloadLen)
+	return loadPayload(&decoder{r: bufio.NewReader(sr), opts: o}, e.Type)
+}
+
+// LoadEntry is LoadOptions.LoadEntry with default options.
+func LoadEntry(ra io.ReaderAt, e IndexEntry) (Tag, error) {
+	return LoadOptions{}.LoadEntry(ra, e)
+}
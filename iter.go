@@ -0,0 +1,105 @@
+package nbt
+
+import (
+	"errors"
+	"iter"
+)
+
+// Iterators for use with range-over-func.
+
+// errStopIteration stops an Iterate call when the loop body breaks.
+var errStopIteration = errors.New("stop iteration")
+
+// All yields the index and value of each element of l.
+func (l List) All() iter.Seq2[int, Tag] {
+	return func(yield func(int, Tag) bool) {
+		if l.Length() == 0 {
+			return
+		}
+		l.Iterate(func(i int, t Tag) error {
+			if !yield(i, t) {
+				return errStopIteration
+			}
+			return nil
+		})
+	}
+}
+
+// Elements yields the index and value of each element of l, which must be
+// a list of T; otherwise it yields nothing. Unlike List.All, the values
+// aren't converted to Tag along the way.
+func Elements[T Tag](l List) iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		data, _ := l.data.(TypedList[T])
+		for i, v := range data {
+			if !yield(i, v) {
+				return
+			}
+		}
+	}
+}
+
+// Sorted yields the entries of c in sorted order of their keys, unlike
+// ranging over the map directly.
+func (c Compound) Sorted() iter.Seq2[String, Tag] {
+	return func(yield func(String, Tag) bool) {
+		for _, k := range sortedKeys(c) {
+			v, ok := c.entry(k)
+			if !ok {
+				// deleted during iteration
+				continue
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// All yields the index and value of each element of b as a Byte.
+func (b ByteArray) All() iter.Seq2[int, Byte] {
+	return func(yield func(int, Byte) bool) {
+		for i, v := range b {
+			if !yield(i, Byte(v)) {
+				return
+			}
+		}
+	}
+}
+
+// All yields the index and value of each element of a. It's equivalent
+// to ranging over a directly, but matches ByteArray.All.
+func (a IntArray) All() iter.Seq2[int, Int] {
+	return func(yield func(int, Int) bool) {
+		for i, v := range a {
+			if !yield(i, v) {
+				return
+			}
+		}
+	}
+}
+
+// All yields the index and value of each element of a. It's equivalent
+// to ranging over a directly, but matches ByteArray.All.
+func (a LongArray) All() iter.Seq2[int, Long] {
+	return func(yield func(int, Long) bool) {
+		for i, v := range a {
+			if !yield(i, v) {
+				return
+			}
+		}
+	}
+}
+
+// All yields every node under root, and root itself, along with its path,
+// in the same order as Walk. Array elements aren't included.
+func All(root Tag) iter.Seq2[Path, Tag] {
+	return func(yield func(Path, Tag) bool) {
+		Walk(root, func(p Path, t Tag) error {
+			if !yield(p, t) {
+				return SkipAll
+			}
+			return nil
+		})
+	}
+}
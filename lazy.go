@@ -0,0 +1,240 @@
+package nbt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Lazy decoding. With LoadOptions.Lazy, the container-typed entries of
+// compounds (compounds, lists, and arrays) aren't decoded when loaded.
+// Instead, the compound holds a placeholder with the entry's raw payload,
+// which is decoded the first time the entry is looked up through
+// TagElement, Get, a Path, or any of the other accessors. The placeholder
+// keeps the decoded value, so later lookups yield the same value, and
+// changes made to it in place are part of the tree. Lookups don't modify
+// the compound itself, so a lazily loaded tree can be read concurrently.
+// Entries which were never decoded are stored out verbatim.
+//
+// Accessing a Compound's map directly, by indexing or ranging over it,
+// can yield these placeholders, which are Tags of the expected type, but
+// not of the expected Go type, so c[k].(Compound) fails. Decode replaces
+// everything still pending in a tree with its decoded value, after which
+// it's safe to access directly.
+
+// lazyTag is a placeholder for an entry which may not have been decoded
+// yet. The raw payload is never modified, but once decoded, the value is
+// shared by everything holding the placeholder.
+type lazyTag struct {
+	typ  Type
+	raw  []byte
+	opts LoadOptions
+	once sync.Once
+	done atomic.Bool
+	val  Tag
+	err  error
+}
+
+func (lz *lazyTag) Type() Type {
+	return lz.typ
+}
+
+// Store writes the decoded value, if the placeholder has been decoded,
+// and otherwise the raw payload unchanged.
+func (lz *lazyTag) Store(w io.Writer) error {
+	if v := lz.decoded(); v != nil {
+		return v.Store(w)
+	}
+	_, err := w.Write(lz.raw)
+	return err
+}
+
+// decode decodes the payload the first time it's called, and yields the
+// same value, or error, every time.
+func (lz *lazyTag) decode() (Tag, error) {
+	lz.once.Do(func() {
+		lz.val, lz.err = loadPayload(&decoder{r: bytes.NewReader(lz.raw), opts: lz.opts}, lz.typ)
+		if lz.err != nil {
+			lz.val = nil
+		}
+		lz.done.Store(true)
+	})
+	return lz.val, lz.err
+}
+
+// decoded yields the decoded value, or nil if the placeholder hasn't been
+// successfully decoded, without decoding it.
+func (lz *lazyTag) decoded() Tag {
+	if !lz.done.Load() {
+		return nil
+	}
+	return lz.val
+}
+
+// copy yields an independent copy of lz, decoded or not.
+func (lz *lazyTag) copy() Tag {
+	if v := lz.decoded(); v != nil {
+		return TagCopy(v)
+	}
+	return &lazyTag{typ: lz.typ, raw: lz.raw, opts: lz.opts}
+}
+
+// lazyType indicates whether payloads of typ are worth deferring.
+func lazyType(typ Type) bool {
+	switch typ {
+	case TypeByteArray, TypeList, TypeCompound, TypeIntArray, TypeLongArray:
+		return true
+	}
+	return false
+}
+
+// loadLazy reads a payload of type typ without decoding it.
+func loadLazy(d *decoder, typ Type) (Tag, error) {
+	buf := &bytes.Buffer{}
+	if err := skipPayload(io.TeeReader(d.r, buf), typ); err != nil {
+		return nil, err
+	}
+	return &lazyTag{typ: typ, raw: buf.Bytes(), opts: d.opts}, nil
+}
+
+// payloadSizes holds the sizes of fixed-size payloads.
+var payloadSizes = [TypeMax]int64{
+	TypeByte:   1,
+	TypeShort:  2,
+	TypeInt:    4,
+	TypeLong:   8,
+	TypeFloat:  4,
+	TypeDouble: 8,
+}
+
+// skip discards n bytes from r.
+func skip(r io.Reader, n int64) error {
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
+}
+
+// skipCount reads a length prefix, and then skips that many elements of
+// the given size.
+func skipCount(r io.Reader, size int64) error {
+	n, err := loadInt(r)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("invalid negative count: %d", n)
+	}
+	return skip(r, int64(n)*size)
+}
+
+// skipPayload reads past a payload of type typ, doing as little work as
+// it can.
+func skipPayload(r io.Reader, typ Type) error {
+	if typ < TypeMax && payloadSizes[typ] != 0 {
+		return skip(r, payloadSizes[typ])
+	}
+	switch typ {
+	case TypeEnd:
+		return nil
+	case TypeByteArray:
+		return skipCount(r, 1)
+	case TypeIntArray:
+		return skipCount(r, 4)
+	case TypeLongArray:
+		return skipCount(r, 8)
+	case TypeString:
+		n, err := loadShort(r)
+		if err != nil {
+			return err
+		}
+		return skip(r, int64(uint16(n)))
+	case TypeList:
+		elemType, err := loadByte(r)
+		if err != nil {
+			return err
+		}
+		if typ := Type(elemType); typ < TypeMax && payloadSizes[typ] != 0 {
+			return skipCount(r, payloadSizes[typ])
+		}
+		n, err := loadInt(r)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid negative count for list: %d", n)
+		}
+		for i := 0; i < int(n); i++ {
+			if err = skipPayload(r, Type(elemType)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TypeCompound:
+		for {
+			t, err := loadByte(r)
+			if err != nil {
+				return err
+			}
+			if Type(t) == TypeEnd {
+				return nil
+			}
+			if err = skipPayload(r, TypeString); err != nil {
+				return err
+			}
+			if err = skipPayload(r, Type(t)); err != nil {
+				return err
+			}
+		}
+	}
+	// custom types can only be skipped by loading them
+	_, err := loadExtension(r, typ)
+	return err
+}
+
+// entry yields the value under key in c, decoding it first if it was
+// loaded lazily, without modifying c. A value which fails to decode is
+// yielded as it is, still a placeholder.
+func (c Compound) entry(key String) (Tag, bool) {
+	t, ok := c[key]
+	if lz, isLazy := t.(*lazyTag); isLazy {
+		if d, err := lz.decode(); err == nil {
+			t = d
+		}
+	}
+	return t, ok
+}
+
+// Decode decodes any lazily-loaded values anywhere in t, replacing them
+// in their compounds, and yields the decoded t. It reports the first
+// value which fails to decode.
+func Decode(t Tag) (Tag, error) {
+	if lz, ok := t.(*lazyTag); ok {
+		d, err := lz.decode()
+		if err != nil {
+			return t, err
+		}
+		t = d
+	}
+	switch x := t.(type) {
+	case Compound:
+		for k, v := range x {
+			d, err := Decode(v)
+			x[k] = d
+			if err != nil {
+				return x, fmt.Errorf("%s: %w", k, err)
+			}
+		}
+	case List:
+		return x, x.Iterate(func(i int, v Tag) error {
+			// lists can't hold placeholders themselves, but their
+			// compounds can
+			_, err := Decode(v)
+			if err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+			return nil
+		})
+	}
+	return t, nil
+}
@@ -0,0 +1,397 @@
+package nbt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// List is implemented in terms of TypedList, a generic slice of a specific
+// tag type. A List holds one of those, type-erased, along with its Contents
+// type. The code in typegen.go (generated from type.tmp) only provides
+// the per-type convenience functions, like GetIntList and MakeIntList.
+
+// TypedList is a list of a specific tag type. It can be used directly as a
+// slice, and converted to a List, which is a Tag, for storage in a Compound
+// or another List. A TypedList of an interface type, such as Tag, has no
+// element type of its own, and converts to a List as MakeMixedList would.
+type TypedList[T Tag] []T
+
+// List wraps tl as a List.
+func (tl TypedList[T]) List() List {
+	return NewList([]T(tl))
+}
+
+// Contents yields the tag type of the list's elements, or TypeEnd if T is
+// an interface type.
+func (tl TypedList[T]) Contents() Type {
+	return elemType[T]()
+}
+
+// elemType yields the tag type of T. If T is an interface type, such as
+// Tag, the zero value is nil and has no Type, so it yields TypeEnd.
+func elemType[T Tag]() Type {
+	var zero T
+	if Tag(zero) == nil {
+		return TypeEnd
+	}
+	return zero.Type()
+}
+
+// NewList makes a List containing elems. Since a List of End can't have
+// any elements, a List[End] is always empty. If T is an interface type,
+// such as Tag, the list is made by MustMakeMixedList, so it panics if an
+// element is nil or End.
+func NewList[T Tag](elems []T) List {
+	var zero T
+	if Tag(zero) == nil {
+		tags := make([]Tag, len(elems))
+		for i, v := range elems {
+			tags[i] = v
+		}
+		return MustMakeMixedList(tags)
+	}
+	l := List{Contents: zero.Type()}
+	if l.Contents != TypeEnd {
+		l.data = TypedList[T](elems)
+	}
+	return l
+}
+
+// AsTypedList yields the elements of l as a TypedList[T], if l is a list
+// of T. The result shares storage with l.
+func AsTypedList[T Tag](l List) (TypedList[T], bool) {
+	var zero T
+	if Tag(zero) == nil || l.Contents != zero.Type() {
+		return nil, false
+	}
+	if l.data == nil {
+		return TypedList[T]{}, true
+	}
+	data, ok := l.data.(TypedList[T])
+	return data, ok
+}
+
+// listData is the type-erased interface to a TypedList, which lets List
+// operations work without knowing the element type.
+type listData interface {
+	length() int
+	element(i int) Tag
+	iterate(fn func(int, Tag) error) error
+	appendData(dst []byte) ([]byte, error)
+	set(i int, t Tag) error
+	insert(i int, t Tag) (listData, error)
+	remove(i int) listData
+}
+
+func (tl TypedList[T]) length() int {
+	return len(tl)
+}
+
+func (tl TypedList[T]) element(i int) Tag {
+	return tl[i]
+}
+
+func (tl TypedList[T]) iterate(fn func(int, Tag) error) error {
+	for i, v := range tl {
+		if err := fn(i, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tl TypedList[T]) appendData(dst []byte) ([]byte, error) {
+	// the common element types are appended directly, to avoid
+	// converting each element to a Tag
+	switch x := any(tl).(type) {
+	case TypedList[Byte]:
+		for _, v := range x {
+			dst = append(dst, byte(v))
+		}
+		return dst, nil
+	case TypedList[Short]:
+		for _, v := range x {
+			dst = binary.BigEndian.AppendUint16(dst, uint16(v))
+		}
+		return dst, nil
+	case TypedList[Int]:
+		for _, v := range x {
+			dst = binary.BigEndian.AppendUint32(dst, uint32(v))
+		}
+		return dst, nil
+	case TypedList[Long]:
+		for _, v := range x {
+			dst = binary.BigEndian.AppendUint64(dst, uint64(v))
+		}
+		return dst, nil
+	case TypedList[Float]:
+		for _, v := range x {
+			dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(float32(v)))
+		}
+		return dst, nil
+	case TypedList[Double]:
+		for _, v := range x {
+			dst = binary.BigEndian.AppendUint64(dst, math.Float64bits(float64(v)))
+		}
+		return dst, nil
+	case TypedList[String]:
+		var err error
+		for _, v := range x {
+			if dst, err = appendString(dst, v); err != nil {
+				return dst, err
+			}
+		}
+		return dst, nil
+	}
+	var err error
+	for _, v := range tl {
+		if dst, err = appendPayload(dst, v); err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+func (tl TypedList[T]) set(i int, t Tag) error {
+	v, ok := t.(T)
+	if !ok {
+		return ListTypeError{Contents: tl.Contents(), Got: t.Type()}
+	}
+	tl[i] = v
+	return nil
+}
+
+func (tl TypedList[T]) insert(i int, t Tag) (listData, error) {
+	v, ok := t.(T)
+	if !ok {
+		return tl, ListTypeError{Contents: tl.Contents(), Got: t.Type()}
+	}
+	return slices.Insert(tl, i, v), nil
+}
+
+func (tl TypedList[T]) remove(i int) listData {
+	return slices.Delete(tl, i, i+1)
+}
+
+// listKind holds the operations which need to know a list's element type
+// before there's a TypedList to call methods on.
+type listKind struct {
+	fromTags func(in []Tag) (listData, error)
+	load     func(r io.Reader, count int) (listData, error)
+}
+
+func makeListKind[T Tag](load func(io.Reader) (T, error)) listKind {
+	return listKind{
+		fromTags: func(in []Tag) (listData, error) {
+			out := make(TypedList[T], len(in))
+			for i, t := range in {
+				v, ok := t.(T)
+				if !ok {
+					return nil, ListTypeError{Contents: out.Contents(), Got: t.Type()}
+				}
+				out[i] = v
+			}
+			return out, nil
+		},
+		load: func(r io.Reader, count int) (listData, error) {
+			out := make(TypedList[T], count)
+			for i := range out {
+				v, err := load(r)
+				if err != nil {
+					return out[:i], err
+				}
+				out[i] = v
+			}
+			return out, nil
+		},
+	}
+}
+
+// listKinds is populated in init because loadList refers to it.
+var listKinds [TypeMax]listKind
+
+func init() {
+	listKinds = [TypeMax]listKind{
+		TypeByte:      makeListKind(loadByte),
+		TypeShort:     makeListKind(loadShort),
+		TypeInt:       makeListKind(loadInt),
+		TypeLong:      makeListKind(loadLong),
+		TypeFloat:     makeListKind(loadFloat),
+		TypeDouble:    makeListKind(loadDouble),
+		TypeByteArray: makeListKind(loadByteArray),
+		TypeString:    makeListKind(loadString),
+		TypeList:      makeListKind(loadList),
+		TypeCompound:  makeListKind(loadCompound),
+		TypeIntArray:  makeListKind(loadIntArray),
+		TypeLongArray: makeListKind(loadLongArray),
+	}
+}
+
+// kind yields the listKind for l's Contents, or nil for End or invalid
+// types.
+func (l List) kind() *listKind {
+	if l.Contents >= TypeMax {
+		return extensionListKind(l.Contents)
+	}
+	if l.Contents == TypeEnd {
+		return nil
+	}
+	return &listKinds[l.Contents]
+}
+
+// loadData loads count elements of the list's Contents type.
+func (l *List) loadData(r io.Reader, count int) (err error) {
+	if l.Contents == TypeEnd {
+		// nothing to load
+		l.data = nil
+		return nil
+	}
+	k := l.kind()
+	if k == nil {
+		return fmt.Errorf("unhandled tag type in List.loadData: %v", l.Contents)
+	}
+	l.data, err = k.load(r, count)
+	return err
+}
+
+// makeListFromTags builds a list of the given type out of a slice of Tags,
+// all of which must actually be of that type.
+func makeListFromTags(typ Type, in []Tag) (l List, err error) {
+	l.Contents = typ
+	if typ == TypeEnd {
+		if len(in) != 0 {
+			return l, fmt.Errorf("can't make a non-empty list of End")
+		}
+		return l, nil
+	}
+	k := l.kind()
+	if k == nil {
+		return l, fmt.Errorf("unhandled tag type in makeListFromTags: %v", typ)
+	}
+	l.data, err = k.fromTags(in)
+	return l, err
+}
+
+// Iterate iterates over the list, passing each item in the list (as a Payload)
+// to the given function. If fn returns a non-nil error, Iterate stops and returns
+// the error.
+func (l List) Iterate(fn func(int, Tag) error) error {
+	if l.data == nil {
+		return nil
+	}
+	return l.data.iterate(fn)
+}
+
+// Length returns the length of the list, if applicable. Note, a list of End
+// is (I think) always of length 0, if it's even valid at all.
+func (l List) Length() int {
+	if l.data == nil {
+		return 0
+	}
+	return l.data.length()
+}
+
+// Element gives the ith element of l.
+func (l List) Element(i int) (out Tag, ok bool) {
+	if i < 0 || i >= l.Length() {
+		return nil, false
+	}
+	return l.data.element(i), true
+}
+
+// ListTypeError indicates an attempt to put a tag of the wrong type into
+// a List.
+type ListTypeError struct {
+	Contents, Got Type
+}
+
+func (e ListTypeError) Error() string {
+	return fmt.Sprintf("can't put %v in list of %v", e.Got, e.Contents)
+}
+
+// check verifies that t can be stored in l as-is. Mixed lists can hold
+// anything but End.
+func (l List) check(t Tag) error {
+	if t == nil {
+		return fmt.Errorf("can't put nil tag in list")
+	}
+	if l.Mixed() && t.Type() != TypeEnd {
+		return nil
+	}
+	if t.Type() != l.Contents {
+		return ListTypeError{Contents: l.Contents, Got: t.Type()}
+	}
+	return nil
+}
+
+// adopt prepares l to have t added to it. An empty list of End becomes a
+// list of t's type; otherwise t has to match the list's type.
+func (l List) adopt(t Tag) (List, error) {
+	if t == nil {
+		return l, fmt.Errorf("can't put nil tag in list")
+	}
+	if t.Type() == TypeEnd {
+		return l, ListTypeError{Contents: l.Contents, Got: TypeEnd}
+	}
+	if l.Contents == TypeEnd && l.Length() == 0 {
+		l.Contents = t.Type()
+		l.data = nil
+	}
+	if err := l.check(t); err != nil {
+		return l, err
+	}
+	if l.data == nil {
+		// a List{Contents: x} literal has no slice yet
+		return makeListFromTags(l.Contents, nil)
+	}
+	return l, nil
+}
+
+// Append appends t to l. t must be of l's Contents type, unless l is an
+// empty list of End, in which case it becomes a list of t's type. Like the
+// built-in append, the result may share storage with l.
+func (l List) Append(t Tag) (List, error) {
+	return l.Insert(l.Length(), t)
+}
+
+// Set replaces the ith element of l with t, which must be of the list's
+// type. Like assigning to a slice element, this modifies storage shared
+// with any copies of l.
+func (l List) Set(i int, t Tag) (List, error) {
+	if i < 0 || i >= l.Length() {
+		return l, fmt.Errorf("index %d out of range for list of length %d", i, l.Length())
+	}
+	if err := l.check(t); err != nil {
+		return l, err
+	}
+	return l, l.data.set(i, t)
+}
+
+// Insert inserts t at index i of l, which may be equal to l's length to
+// append to it. See Append for type rules. Like slices.Insert, the result
+// may share storage with l.
+func (l List) Insert(i int, t Tag) (List, error) {
+	if i < 0 || i > l.Length() {
+		return l, fmt.Errorf("index %d out of range for list of length %d", i, l.Length())
+	}
+	l, err := l.adopt(t)
+	if err != nil {
+		return l, err
+	}
+	l.data, err = l.data.insert(i, t)
+	return l, err
+}
+
+// Remove removes the ith element of l. The list keeps its Contents type
+// even if it becomes empty. Like slices.Delete, this modifies storage
+// shared with l.
+func (l List) Remove(i int) (List, error) {
+	if i < 0 || i >= l.Length() {
+		return l, fmt.Errorf("index %d out of range for list of length %d", i, l.Length())
+	}
+	l.data = l.data.remove(i)
+	return l, nil
+}
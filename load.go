@@ -12,6 +12,43 @@ import (
 
 // Functions related to loading NBT tags from streams.
 
+// LoadOptions controls optional behavior when loading tags. The zero
+// value gives the same behavior as the plain Load functions.
+type LoadOptions struct {
+	// KeepWrappedLists leaves heterogeneous lists in their stored form,
+	// a list of compounds each holding a single value under an empty
+	// key, rather than unwrapping them. See MakeMixedList.
+	KeepWrappedLists bool
+	// Lazy defers decoding compound entries which are compounds, lists,
+	// or arrays until they're looked up. See Decode.
+	Lazy bool
+	// Include, if not empty, limits decoding to the nodes selected by
+	// the given paths. See LoadPaths.
+	Include []NBTPath
+}
+
+// decoder wraps the reader being loaded from, along with the options
+// controlling how it's loaded. It's an io.Reader itself, so the loaders
+// for simple types don't need to know about it, and loaders which do
+// care can find it again with asDecoder.
+type decoder struct {
+	r    io.Reader
+	opts LoadOptions
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	return d.r.Read(p)
+}
+
+// asDecoder yields r if it's already a decoder, or else a decoder with
+// default options reading from r.
+func asDecoder(r io.Reader) *decoder {
+	if d, ok := r.(*decoder); ok {
+		return d
+	}
+	return &decoder{r: r}
+}
+
 // loadByte loads a Byte payload.
 func loadByte(r io.Reader) (b Byte, e error) {
 	var buf [1]byte
@@ -157,7 +194,7 @@ func loadList(r io.Reader) (l List, e error) {
 	if e != nil {
 		return l, e
 	}
-	if Type(ttype) < TypeEnd || Type(ttype) >= TypeMax {
+	if !validType(Type(ttype)) {
 		return l, fmt.Errorf("invalid tag type for list: %d", ttype)
 	}
 	count, e := loadInt(r)
@@ -169,18 +206,39 @@ func loadList(r io.Reader) (l List, e error) {
 	}
 	l.Contents = Type(ttype)
 	e = l.loadData(r, int(count))
+	if e == nil && l.Contents == TypeCompound && !asDecoder(r).opts.KeepWrappedLists {
+		l = unwrapList(l)
+	}
 	return l, e
 }
 
 // loadCompound loads a Compound tag, thus, loads other tags until it gets
-// a TypeEnd.
+// a TypeEnd. In lazy mode, entries which are containers are loaded as
+// placeholders.
 func loadCompound(r io.Reader) (c Compound, e error) {
 	c = make(map[String]Tag)
-	var t Tag
-	var name String
-	var err error
+	d := asDecoder(r)
 	var errored error // an error we handle after the fact
-	for t, name, err = LoadUncompressed(r); err == nil && t.Type() != TypeEnd; t, name, err = LoadUncompressed(r) {
+	for {
+		typ, name, err := loadHeader(r)
+		if err == io.EOF {
+			return c, fmt.Errorf("unterminated compound tag")
+		}
+		if err != nil {
+			return c, err
+		}
+		if typ == TypeEnd {
+			break
+		}
+		var t Tag
+		if d.opts.Lazy && lazyType(typ) {
+			t, err = loadLazy(d, typ)
+		} else {
+			t, err = loadPayload(d, typ)
+		}
+		if err != nil {
+			return c, err
+		}
 		// fmt.Printf("loaded tag: [%v] %s\n", t.Type, t.Name)
 		_, ok := c[name]
 		if ok {
@@ -189,42 +247,69 @@ func loadCompound(r io.Reader) (c Compound, e error) {
 		}
 		c[name] = t
 	}
-	if t.Type() != TypeEnd {
-		fmt.Printf("failed load compound\n")
-		return c, fmt.Errorf("unterminated compound tag")
-	}
 	return c, errored
 }
 
 // LoadCompressed reads the first Tag found in the gzipped stream r.
 func LoadCompressed(r io.Reader) (Tag, String, error) {
+	return LoadOptions{}.LoadCompressed(r)
+}
+
+// LoadCompressed is like the LoadCompressed function, using the options
+// in o.
+func (o LoadOptions) LoadCompressed(r io.Reader) (Tag, String, error) {
 	uncomp, err := gzip.NewReader(r)
 	if err != nil {
 		return nil, "", err
 	}
 	defer uncomp.Close()
-	return LoadUncompressed(uncomp)
+	return o.LoadUncompressed(uncomp)
 }
 
-// LoadUncompressed reads the first Tag found in the uncompressed
-// stream r.
-func LoadUncompressed(r io.Reader) (Tag, String, error) {
+// LoadUncompressed is like the LoadUncompressed function, using the
+// options in o.
+func (o LoadOptions) LoadUncompressed(r io.Reader) (Tag, String, error) {
+	return LoadUncompressed(&decoder{r: r, opts: o})
+}
+
+// loadHeader loads a tag's type and name, which End doesn't have.
+func loadHeader(r io.Reader) (Type, String, error) {
 	var tagByte [1]byte
 	_, err := io.ReadFull(r, tagByte[0:1])
 	if err != nil {
-		return nil, "", err
+		return TypeEnd, "", err
 	}
 	typ := Type(tagByte[0])
 	if typ == TypeEnd {
-		return End{}, "", nil
+		return typ, "", nil
 	}
 	// every tag other than TypeEnd has a name:
 	name, err := loadString(r)
+	return typ, name, err
+}
+
+// LoadUncompressed reads the first Tag found in the uncompressed
+// stream r.
+func LoadUncompressed(r io.Reader) (Tag, String, error) {
+	typ, name, err := loadHeader(r)
 	if err != nil {
 		return nil, "", err
 	}
-	var t Tag
+	if typ == TypeEnd {
+		return End{}, "", nil
+	}
 	// fmt.Printf("load: %s [%v]\n", n.Name, n.Type)
+	var t Tag
+	if d, ok := r.(*decoder); ok && len(d.opts.Include) != 0 {
+		t, _, err = loadSelected(d, typ, rootSelection(d.opts.Include))
+	} else {
+		t, err = loadPayload(r, typ)
+	}
+	return t, name, err
+}
+
+// loadPayload loads the payload of a tag of type typ.
+func loadPayload(r io.Reader, typ Type) (t Tag, err error) {
 	switch typ {
 	case TypeByte:
 		t, err = loadByte(r)
@@ -251,28 +336,41 @@ func LoadUncompressed(r io.Reader) (Tag, String, error) {
 	case TypeLongArray:
 		t, err = loadLongArray(r)
 	default:
-		err = fmt.Errorf("unsupported tag type %v", typ)
-	}
-	if err != nil {
-		fmt.Printf("failed to load %s: %s\n", name, err)
+		t, err = loadExtension(r, typ)
 	}
-	return t, name, err
+	return t, err
 }
 
 // Load attempts to determine whether the stream r is compressed or not,
 // and use LoadCompressed/LoadUncompressed accordingly.
 func Load(r io.Reader) (Tag, String, error) {
+	return LoadOptions{}.Load(r)
+}
+
+// Load is like the Load function, using the options in o.
+func (o LoadOptions) Load(r io.Reader) (Tag, String, error) {
+	src, err := decompress(r)
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+	return o.LoadUncompressed(src)
+}
+
+// decompress yields a reader of the uncompressed contents of r, which
+// may or may not be compressed.
+func decompress(r io.Reader) (io.ReadCloser, error) {
 	buf := bufio.NewReader(r)
 	header, err := buf.Peek(512)
 	// couldn't read the thing
 	if err != nil && err != io.EOF {
-		return nil, "", err
+		return nil, err
 	}
 	readBuf := bytes.NewBuffer(header)
 	gz, err := gzip.NewReader(readBuf)
 	if err == nil {
 		gz.Close()
-		return LoadCompressed(buf)
+		return gzip.NewReader(buf)
 	}
-	return LoadUncompressed(buf)
+	return io.NopCloser(buf), nil
 }
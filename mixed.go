@@ -0,0 +1,163 @@
+package nbt
+
+import (
+	"fmt"
+)
+
+// Heterogeneous lists. Since 1.21.5, Minecraft allows lists whose elements
+// aren't all the same type. On disk, these are lists of compounds, with
+// each element wrapped in a compound under the empty key. Compound elements
+// are only wrapped if they'd otherwise look like a wrapper.
+//
+// A mixed list is a List with Contents TypeCompound, its stored type, and
+// elements of any type. Loading unwraps lists containing wrappers, unless
+// LoadOptions.KeepWrappedLists is set, and storing wraps elements again.
+
+// mixedList is the listData for a mixed list.
+type mixedList struct {
+	elems []Tag
+}
+
+func (ml mixedList) length() int {
+	return len(ml.elems)
+}
+
+func (ml mixedList) element(i int) Tag {
+	return ml.elems[i]
+}
+
+func (ml mixedList) iterate(fn func(int, Tag) error) error {
+	for i, v := range ml.elems {
+		if err := fn(i, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ml mixedList) appendData(dst []byte) ([]byte, error) {
+	var err error
+	for _, v := range ml.elems {
+		if dst, err = appendPayload(dst, wrapElement(v)); err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+func (ml mixedList) set(i int, t Tag) error {
+	ml.elems[i] = t
+	return nil
+}
+
+func (ml mixedList) insert(i int, t Tag) (listData, error) {
+	ml.elems = append(ml.elems, nil)
+	copy(ml.elems[i+1:], ml.elems[i:])
+	ml.elems[i] = t
+	return ml, nil
+}
+
+func (ml mixedList) remove(i int) listData {
+	ml.elems = append(ml.elems[:i], ml.elems[i+1:]...)
+	return ml
+}
+
+// isWrapper indicates whether c has the shape of a wrapped list element.
+func isWrapper(c Compound) bool {
+	_, ok := c[""]
+	return ok && len(c) == 1
+}
+
+// wrapElement yields the stored form of an element of a mixed list.
+func wrapElement(t Tag) Compound {
+	if c, ok := t.(Compound); ok && !isWrapper(c) {
+		return c
+	}
+	return Compound{"": t}
+}
+
+// unwrapList unwraps the elements of a freshly loaded list of compounds,
+// if any of them are wrappers. If the unwrapped elements are all the same
+// type, the result is an ordinary list of that type.
+func unwrapList(l List) List {
+	wrapped := false
+	l.Iterate(func(_ int, t Tag) error {
+		if isWrapper(t.(Compound)) {
+			wrapped = true
+			return errFound
+		}
+		return nil
+	})
+	if !wrapped {
+		return l
+	}
+	elems := make([]Tag, 0, l.Length())
+	l.Iterate(func(_ int, t Tag) error {
+		c := t.(Compound)
+		if isWrapper(c) {
+			v, _ := c.entry("")
+			elems = append(elems, v)
+		} else {
+			elems = append(elems, c)
+		}
+		return nil
+	})
+	out, err := MakeMixedList(elems)
+	if err != nil {
+		// can only happen with a wrapped End, which we leave alone
+		return l
+	}
+	return out
+}
+
+// MakeMixedList makes a List containing elems, which can be of any types
+// other than End. If they're all the same type, the result is an ordinary
+// list of that type, which is how Minecraft does it.
+func MakeMixedList(elems []Tag) (List, error) {
+	if len(elems) == 0 {
+		return List{Contents: TypeEnd}, nil
+	}
+	mixed := false
+	for i, t := range elems {
+		if t == nil || t.Type() == TypeEnd {
+			return List{}, fmt.Errorf("can't put %v in mixed list at index %d", t, i)
+		}
+		if t.Type() != elems[0].Type() {
+			mixed = true
+		}
+	}
+	if !mixed {
+		return makeListFromTags(elems[0].Type(), elems)
+	}
+	return List{Contents: TypeCompound, data: mixedList{elems: append([]Tag{}, elems...)}}, nil
+}
+
+// MustMakeMixedList is like MakeMixedList, but panics on error. It's
+// intended for lists which are constants in source code.
+func MustMakeMixedList(elems []Tag) List {
+	l, err := MakeMixedList(elems)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// Mixed indicates whether l is a mixed list, which can hold elements of
+// any type.
+func (l List) Mixed() bool {
+	_, ok := l.data.(mixedList)
+	return ok
+}
+
+// AsMixed yields l with the elements of a mixed list unwrapped. A list of
+// compounds in the stored form of a mixed list, as loaded with
+// LoadOptions.KeepWrappedLists, becomes a mixed list, or an ordinary list
+// if the unwrapped elements are all the same type. Any other list is
+// returned unchanged; to add elements of other types to a list, make a
+// new one with MakeMixedList.
+func (l List) AsMixed() List {
+	if l.Contents != TypeCompound || l.Mixed() {
+		return l
+	}
+	return unwrapList(l)
+}
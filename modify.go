@@ -0,0 +1,425 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Functionality corresponding to Minecraft's `/data modify` and `/data remove`
+// commands, applied to NBT paths.
+
+var (
+	NBTPathNoMatch = errors.New("found no elements matching path")
+	NBTPathAtRoot  = errors.New("can't set or remove the root tag")
+)
+
+// nbtPathUpdate is called with each tag selected by a path, or with nil if
+// the tag doesn't exist but should be created. It returns the replacement,
+// or nil to remove it, and the number of changes it made.
+type nbtPathUpdate func(old Tag) (Tag, int, error)
+
+// updateNodes calls fn on every tag selected by nodes, starting from t, and
+// yields the updated t. If create is true, missing intermediate tags are
+// created along the way, but only kept if something under them changed.
+// Tags along the way are copied rather than modified, so t is unchanged.
+func updateNodes(t Tag, nodes []nbtPathNode, create bool, fn nbtPathUpdate) (Tag, int, error) {
+	if len(nodes) == 0 {
+		return fn(t)
+	}
+	node, rest := nodes[0], nodes[1:]
+	return node.update(t, create, func(child Tag) (Tag, int, error) {
+		if child != nil || len(rest) == 0 {
+			return updateNodes(child, rest, create, fn)
+		}
+		out, count, err := updateNodes(rest[0].parentDefault(), rest, create, fn)
+		if err != nil || count == 0 {
+			// don't add a new parent with nothing in it
+			return nil, 0, err
+		}
+		return out, count, nil
+	})
+}
+
+// update applies fn to every node the path matches, and reports an error if
+// there weren't any. On error, root is unchanged. Otherwise, a Compound
+// root is updated in place, though the tags under it which changed are
+// new values, and any other root is replaced.
+func (np NBTPath) update(root Tag, create bool, fn nbtPathUpdate) (Tag, int, error) {
+	found := false
+	out, count, err := updateNodes(root, np.nodes, create, func(old Tag) (Tag, int, error) {
+		found = true
+		return fn(old)
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("%w: %s", NBTPathNoMatch, np)
+	}
+	if err != nil {
+		return root, 0, err
+	}
+	if c, ok := root.(Compound); ok {
+		if updated, ok := out.(Compound); ok {
+			for k := range c {
+				if _, keep := updated[k]; !keep {
+					delete(c, k)
+				}
+			}
+			for k, v := range updated {
+				c[k] = v
+			}
+			out = c
+		}
+	}
+	return out, count, nil
+}
+
+// targetsRoot indicates whether the path's final node is the root itself,
+// in which case it can't be set or removed.
+func (np NBTPath) targetsRoot() bool {
+	_, ok := np.nodes[len(np.nodes)-1].(nbtPathRoot)
+	return ok
+}
+
+// Set replaces every tag matched by the path with a copy of value, creating
+// missing tags along the way, and returns the updated root and the number of
+// tags which actually changed.
+func (np NBTPath) Set(root Tag, value Tag) (Tag, int, error) {
+	if np.targetsRoot() {
+		return root, 0, NBTPathAtRoot
+	}
+	return np.update(root, true, func(old Tag) (Tag, int, error) {
+		if TagEqual(old, value) {
+			return old, 0, nil
+		}
+		return TagCopy(value), 1, nil
+	})
+}
+
+// Merge merges value into every compound matched by the path, the way
+// `/data modify ... merge` does: nested compounds are merged recursively,
+// and anything else in value replaces the existing entry.
+func (np NBTPath) Merge(root Tag, value Compound) (Tag, int, error) {
+	return np.update(root, true, func(old Tag) (Tag, int, error) {
+		if old == nil {
+			old = make(Compound)
+		}
+		c, ok := old.(Compound)
+		if !ok {
+			return old, 0, fmt.Errorf("can't merge into %v", old.Type())
+		}
+		merged := TagCopy(c).(Compound)
+		mergeCompound(merged, value)
+		if TagEqual(c, merged) {
+			return old, 0, nil
+		}
+		return merged, 1, nil
+	})
+}
+
+// mergeCompound recursively merges src into dst.
+func mergeCompound(dst, src Compound) {
+	for k := range src {
+		v, _ := src.entry(k)
+		if sub, ok := v.(Compound); ok {
+			existing, _ := dst.entry(k)
+			if existing, ok := existing.(Compound); ok {
+				mergeCompound(existing, sub)
+				// existing may have been decoded from a lazy entry
+				dst[k] = existing
+				continue
+			}
+		}
+		dst[k] = TagCopy(v)
+	}
+}
+
+// Insert inserts copies of values into every list or array matched by the
+// path, starting at index. A negative index counts from the end, so -1
+// means after the last element.
+func (np NBTPath) Insert(root Tag, index int, values ...Tag) (Tag, int, error) {
+	return np.update(root, true, func(old Tag) (Tag, int, error) {
+		if old == nil {
+			old = List{Contents: TypeEnd}
+		}
+		if !tagIsIndexable(old) {
+			return old, 0, fmt.Errorf("can't insert into %v", old.Type())
+		}
+		elems := collectionElements(old)
+		idx := index
+		if idx < 0 {
+			idx += len(elems) + 1
+		}
+		if idx < 0 || idx > len(elems) {
+			return old, 0, fmt.Errorf("invalid index %d for %v of length %d", index, old.Type(), len(elems))
+		}
+		if len(values) == 0 {
+			return old, 0, nil
+		}
+		updated := make([]Tag, 0, len(elems)+len(values))
+		updated = append(updated, elems[:idx]...)
+		for _, v := range values {
+			updated = append(updated, TagCopy(v))
+		}
+		updated = append(updated, elems[idx:]...)
+		out, err := rebuildCollection(old, updated)
+		if err != nil {
+			return old, 0, err
+		}
+		return out, 1, nil
+	})
+}
+
+// Append adds copies of values to the end of every list or array matched
+// by the path.
+func (np NBTPath) Append(root Tag, values ...Tag) (Tag, int, error) {
+	return np.Insert(root, -1, values...)
+}
+
+// Prepend adds copies of values to the beginning of every list or array
+// matched by the path.
+func (np NBTPath) Prepend(root Tag, values ...Tag) (Tag, int, error) {
+	return np.Insert(root, 0, values...)
+}
+
+// Remove removes every tag matched by the path from its parent.
+func (np NBTPath) Remove(root Tag) (Tag, int, error) {
+	if np.targetsRoot() {
+		return root, 0, NBTPathAtRoot
+	}
+	return np.update(root, false, func(old Tag) (Tag, int, error) {
+		return nil, 1, nil
+	})
+}
+
+// collectionElements yields the elements of a List or array as Tags.
+func collectionElements(t Tag) []Tag {
+	n := TagLength(t)
+	out := make([]Tag, n)
+	for i := 0; i < n; i++ {
+		out[i], _ = TagElement(t, i)
+	}
+	return out
+}
+
+// rebuildCollection makes a new List or array of the same kind as t,
+// containing elems. Lists must contain only one type of element, which
+// is whatever they already contained, or the type of the first element for
+// an empty list, unless they're mixed lists. Arrays can only contain their own element type.
+func rebuildCollection(t Tag, elems []Tag) (Tag, error) {
+	switch x := t.(type) {
+	case List:
+		if x.Mixed() {
+			return MakeMixedList(elems)
+		}
+		typ := x.Contents
+		if len(elems) == 0 {
+			typ = TypeEnd
+		} else if typ == TypeEnd || x.Length() == 0 {
+			typ = elems[0].Type()
+		}
+		return makeListFromTags(typ, elems)
+	case ByteArray:
+		out := make(ByteArray, len(elems))
+		for i, e := range elems {
+			b, ok := e.(Byte)
+			if !ok {
+				return t, fmt.Errorf("can't insert %v into ByteArray", e.Type())
+			}
+			out[i] = int8(b)
+		}
+		return out, nil
+	case IntArray:
+		out := make(IntArray, len(elems))
+		for i, e := range elems {
+			v, ok := e.(Int)
+			if !ok {
+				return t, fmt.Errorf("can't insert %v into IntArray", e.Type())
+			}
+			out[i] = v
+		}
+		return out, nil
+	case LongArray:
+		out := make(LongArray, len(elems))
+		for i, e := range elems {
+			v, ok := e.(Long)
+			if !ok {
+				return t, fmt.Errorf("can't insert %v into LongArray", e.Type())
+			}
+			out[i] = v
+		}
+		return out, nil
+	default:
+		return t, fmt.Errorf("can't rebuild %v as a collection", t.Type())
+	}
+}
+
+// updateElements calls fn on each element of the collection t for which
+// sel returns true, and rebuilds the collection from the results.
+func updateElements(t Tag, sel func(Tag) bool, fn nbtPathUpdate) (Tag, int, error) {
+	elems := collectionElements(t)
+	updated := make([]Tag, 0, len(elems))
+	total := 0
+	for _, e := range elems {
+		if !sel(e) {
+			updated = append(updated, e)
+			continue
+		}
+		n, count, err := fn(e)
+		total += count
+		if err != nil {
+			return t, total, err
+		}
+		if n != nil {
+			updated = append(updated, n)
+		}
+	}
+	if total == 0 {
+		return t, 0, nil
+	}
+	out, err := rebuildCollection(t, updated)
+	if err != nil {
+		return t, 0, err
+	}
+	return out, total, nil
+}
+
+func (n nbtPathRoot) parentDefault() Tag {
+	return make(Compound)
+}
+
+func (n nbtPathRoot) update(t Tag, create bool, fn nbtPathUpdate) (Tag, int, error) {
+	if !TagMatches(n.filter, t) {
+		return t, 0, nil
+	}
+	out, count, err := fn(t)
+	if err != nil {
+		return t, count, err
+	}
+	if out == nil {
+		return t, count, NBTPathAtRoot
+	}
+	return out, count, nil
+}
+
+func (n nbtPathKey) parentDefault() Tag {
+	return make(Compound)
+}
+
+// updateCompoundKey handles the shared logic for updating a named child
+// of a compound; old is nil if the key doesn't exist. The update is made
+// to a copy of c, so an error later on doesn't leave c half-updated.
+func updateCompoundKey(c Compound, key String, old Tag, fn nbtPathUpdate) (Tag, int, error) {
+	out, count, err := fn(old)
+	if err != nil {
+		return c, count, err
+	}
+	if out == nil && old == nil {
+		// nothing to delete
+		return c, count, nil
+	}
+	return copyCompoundWith(c, key, out), count, nil
+}
+
+func (n nbtPathKey) update(t Tag, create bool, fn nbtPathUpdate) (Tag, int, error) {
+	c, ok := t.(Compound)
+	if !ok {
+		return t, 0, nil
+	}
+	old, exists := c.entry(n.key)
+	if !exists && !create {
+		return t, 0, nil
+	}
+	return updateCompoundKey(c, n.key, old, fn)
+}
+
+func (n nbtPathMatchKey) parentDefault() Tag {
+	return make(Compound)
+}
+
+func (n nbtPathMatchKey) update(t Tag, create bool, fn nbtPathUpdate) (Tag, int, error) {
+	c, ok := t.(Compound)
+	if !ok {
+		return t, 0, nil
+	}
+	old, exists := c.entry(n.key)
+	switch {
+	case exists && TagMatches(n.filter, old):
+	case !exists && create:
+		old = TagCopy(n.filter)
+	default:
+		return t, 0, nil
+	}
+	return updateCompoundKey(c, n.key, old, fn)
+}
+
+func (n nbtPathIndex) parentDefault() Tag {
+	return List{Contents: TypeEnd}
+}
+
+func (n nbtPathIndex) update(t Tag, create bool, fn nbtPathUpdate) (Tag, int, error) {
+	if !tagIsIndexable(t) {
+		return t, 0, nil
+	}
+	idx := n.index
+	if idx < 0 {
+		idx += TagLength(t)
+	}
+	if idx < 0 || idx >= TagLength(t) {
+		return t, 0, nil
+	}
+	i := 0
+	return updateElements(t, func(Tag) bool { i++; return i-1 == idx }, fn)
+}
+
+func (n nbtPathAllElements) parentDefault() Tag {
+	return List{Contents: TypeEnd}
+}
+
+func (n nbtPathAllElements) update(t Tag, create bool, fn nbtPathUpdate) (Tag, int, error) {
+	if !tagIsIndexable(t) {
+		return t, 0, nil
+	}
+	if TagLength(t) == 0 && create {
+		// like Minecraft, "all elements" of an empty list gets one
+		// new element when creating things.
+		return appendElement(t, nil, fn)
+	}
+	return updateElements(t, func(Tag) bool { return true }, fn)
+}
+
+func (n nbtPathMatchElements) parentDefault() Tag {
+	return List{Contents: TypeEnd}
+}
+
+func (n nbtPathMatchElements) update(t Tag, create bool, fn nbtPathUpdate) (Tag, int, error) {
+	l, ok := t.(List)
+	if !ok || (l.Contents != TypeCompound && l.Length() != 0) {
+		return t, 0, nil
+	}
+	matched := false
+	sel := func(e Tag) bool {
+		if TagMatches(n.filter, e) {
+			matched = true
+			return true
+		}
+		return false
+	}
+	out, count, err := updateElements(t, sel, fn)
+	if matched || !create || err != nil {
+		return out, count, err
+	}
+	return appendElement(t, TagCopy(n.filter), fn)
+}
+
+// appendElement calls fn on elem, which is a new element, or nil, and
+// appends the result to the collection t.
+func appendElement(t Tag, elem Tag, fn nbtPathUpdate) (Tag, int, error) {
+	n, count, err := fn(elem)
+	if err != nil || n == nil {
+		return t, count, err
+	}
+	out, err := rebuildCollection(t, append(collectionElements(t), n))
+	if err != nil {
+		return t, 0, err
+	}
+	return out, count, nil
+}
@@ -0,0 +1,498 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustSNBT(t *testing.T, s string) Tag {
+	tag, err := ParseSNBT(s)
+	if err != nil {
+		t.Fatalf("parsing %q: %s", s, err)
+	}
+	return tag
+}
+
+func TestNBTPathModify(t *testing.T) {
+	start := `{Inventory:[{Slot:0b,id:"stone",Count:1b},{Slot:1b,id:"dirt",Count:5b}],Pos:[I;1,2,3]}`
+	cases := []struct {
+		name  string
+		op    func(root Tag) (Tag, int, error)
+		count int
+		want  string
+	}{
+		{"set", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Inventory[{Slot:1b}].Count").Set(root, Byte(64))
+		}, 1, `{Inventory:[{Slot:0b,id:"stone",Count:1b},{Slot:1b,id:"dirt",Count:64b}],Pos:[I;1,2,3]}`},
+		{"set unchanged", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Inventory[0].Count").Set(root, Byte(1))
+		}, 0, start},
+		{"set creates", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("a.b").Set(root, String("x"))
+		}, 1, `{Inventory:[{Slot:0b,id:"stone",Count:1b},{Slot:1b,id:"dirt",Count:5b}],Pos:[I;1,2,3],a:{b:"x"}}`},
+		{"set all", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Pos[]").Set(root, Int(0))
+		}, 3, `{Inventory:[{Slot:0b,id:"stone",Count:1b},{Slot:1b,id:"dirt",Count:5b}],Pos:[I;0,0,0]}`},
+		{"merge", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Inventory[0]").Merge(root, Compound{"tag": Compound{"Damage": Int(3)}})
+		}, 1, `{Inventory:[{Slot:0b,id:"stone",Count:1b,tag:{Damage:3}},{Slot:1b,id:"dirt",Count:5b}],Pos:[I;1,2,3]}`},
+		{"append", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Pos").Append(root, Int(4))
+		}, 1, `{Inventory:[{Slot:0b,id:"stone",Count:1b},{Slot:1b,id:"dirt",Count:5b}],Pos:[I;1,2,3,4]}`},
+		{"prepend", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Pos").Prepend(root, Int(0))
+		}, 1, `{Inventory:[{Slot:0b,id:"stone",Count:1b},{Slot:1b,id:"dirt",Count:5b}],Pos:[I;0,1,2,3]}`},
+		{"insert", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Inventory").Insert(root, 1, Compound{"Slot": Byte(2)})
+		}, 1, `{Inventory:[{Slot:0b,id:"stone",Count:1b},{Slot:2b},{Slot:1b,id:"dirt",Count:5b}],Pos:[I;1,2,3]}`},
+		{"append creates", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Tags").Append(root, String("x"))
+		}, 1, `{Inventory:[{Slot:0b,id:"stone",Count:1b},{Slot:1b,id:"dirt",Count:5b}],Pos:[I;1,2,3],Tags:["x"]}`},
+		{"remove", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Inventory[{id:\"stone\"}]").Remove(root)
+		}, 1, `{Inventory:[{Slot:1b,id:"dirt",Count:5b}],Pos:[I;1,2,3]}`},
+		{"remove all", func(root Tag) (Tag, int, error) {
+			return MustParseNBTPath("Inventory[].Count").Remove(root)
+		}, 2, `{Inventory:[{Slot:0b,id:"stone"},{Slot:1b,id:"dirt"}],Pos:[I;1,2,3]}`},
+	}
+	for _, c := range cases {
+		root := mustSNBT(t, start)
+		got, count, err := c.op(root)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+			continue
+		}
+		if count != c.count {
+			t.Errorf("%s: expected %d changes, got %d", c.name, c.count, count)
+		}
+		if want := mustSNBT(t, c.want); !TagEqual(got, want) {
+			t.Errorf("%s: result didn't match %s", c.name, c.want)
+		}
+	}
+}
+
+func TestNBTPathModifyErrors(t *testing.T) {
+	root := mustSNBT(t, `{Inventory:[{Slot:0b}],Pos:[I;1,2,3],Name:"x"}`)
+	if _, _, err := MustParseNBTPath("Pos").Append(root, Long(4)); err == nil {
+		t.Errorf("appending Long to IntArray: expected error")
+	}
+	if _, _, err := MustParseNBTPath("Inventory").Append(root, String("x")); err == nil {
+		t.Errorf("appending String to list of Compound: expected error")
+	}
+	if _, _, err := MustParseNBTPath("Inventory[0]").Set(root, Int(3)); err == nil {
+		t.Errorf("setting list element to wrong type: expected error")
+	}
+	if _, _, err := MustParseNBTPath("Name").Merge(root, Compound{}); err == nil {
+		t.Errorf("merging into String: expected error")
+	}
+	if _, _, err := MustParseNBTPath("Missing").Remove(root); !errors.Is(err, NBTPathNoMatch) {
+		t.Errorf("removing missing entry: expected NBTPathNoMatch, got %v", err)
+	}
+	if _, _, err := MustParseNBTPath("{}").Remove(root); err != NBTPathAtRoot {
+		t.Errorf("removing root: expected NBTPathAtRoot, got %v", err)
+	}
+}
+
+func TestPatchInPlace(t *testing.T) {
+	root := mustSNBT(t, `{Data:{SpawnX:10,Name:"abc",Pos:[1.0d,2.0d],Ids:[I;1,2,3]}}`)
+	f, err := os.Create(filepath.Join(t.TempDir(), "level.dat"))
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	defer f.Close()
+	// the document needn't start at the beginning of the file
+	f.Write([]byte{0xff, 0xff})
+	if err = StoreUncompressed(f, root, ""); err != nil {
+		t.Fatalf("store: %s", err)
+	}
+	path := func(comps ...PathComponent) Path {
+		return Path{Components: comps}
+	}
+	patches := []struct {
+		path  Path
+		value Tag
+		err   error
+	}{
+		{path(String("Data"), String("SpawnX")), Int(-7), nil},
+		{path(String("Data"), String("Name")), String("xyz"), nil},
+		{path(String("Data"), String("Pos"), Int(1)), Double(5), nil},
+		{path(String("Data"), String("Ids"), Int(2)), Int(9), nil},
+		{path(String("Data"), String("Name")), String("toolong"), PatchSizeChanged},
+		{path(String("Data"), String("SpawnX")), Long(1), PatchTypeMismatch},
+	}
+	for _, p := range patches {
+		if _, err = f.Seek(2, io.SeekStart); err != nil {
+			t.Fatalf("seek: %s", err)
+		}
+		err = PatchInPlace(f, p.path, p.value)
+		if !errors.Is(err, p.err) {
+			t.Errorf("patch %s: expected error %v, got %v", p.path, p.err, err)
+		}
+	}
+	if _, err = f.Seek(2, io.SeekStart); err != nil {
+		t.Fatalf("seek: %s", err)
+	}
+	if err = PatchInPlace(f, path(String("Data"), String("Nope")), Int(1)); err == nil {
+		t.Errorf("patching missing entry succeeded")
+	}
+	f.Seek(2, io.SeekStart)
+	got, _, err := LoadUncompressed(f)
+	if err != nil {
+		t.Fatalf("reload: %s", err)
+	}
+	want := mustSNBT(t, `{Data:{SpawnX:-7,Name:"xyz",Pos:[1.0d,5.0d],Ids:[I;1,2,9]}}`)
+	if !TagEqual(got, want) {
+		t.Errorf("expected %s, got %s", FormatSNBT(want), FormatSNBT(got))
+	}
+}
+
+func TestPathEdit(t *testing.T) {
+	root := mustSNBT(t, `{a:{l:[[1,2],[3]],b:[B;1b,2b]},k:1}`)
+	p := NewPath(root)
+	for _, comp := range []PathComponent{String("a"), String("l"), Int(0), Int(1)} {
+		if _, err := p.Cd(comp); err != nil {
+			t.Fatalf("cd %v: %s", comp, err)
+		}
+	}
+	other := NewPath(root)
+	other.Cd(String("a"))
+	other.Cd(String("l"))
+	other.Cd(Int(1))
+	if err := p.Set(Int(7)); err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	if err := p.Set(String("x")); err == nil {
+		t.Errorf("setting String in Int list succeeded")
+	}
+	p.Cd(String(".."))
+	p.Cd(Int(0))
+	if err := p.Delete(); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	if got := p.String(); got != "a/l/0/" {
+		t.Errorf("after delete, expected path a/l/0/, got %s", got)
+	}
+	arr := NewPath(root)
+	arr.Cd(String("a"))
+	arr.Cd(String("b"))
+	arr.Cd(Int(0))
+	if err := arr.Delete(); err != nil {
+		t.Fatalf("delete array element: %s", err)
+	}
+	k := NewPath(root)
+	k.Cd(String("k"))
+	if err := k.Rename("a"); err == nil {
+		t.Errorf("rename onto existing key succeeded")
+	}
+	if err := k.Rename("m"); err != nil {
+		t.Errorf("rename: %s", err)
+	}
+	top := NewPath(root)
+	if err := top.Delete(); err != PathAtRoot {
+		t.Errorf("deleting root: expected PathAtRoot, got %v", err)
+	}
+	want := mustSNBT(t, `{a:{l:[[7],[3]],b:[B;2b]},m:1}`)
+	if !TagEqual(root, want) {
+		t.Errorf("expected %s, got %s", FormatSNBT(want), FormatSNBT(root))
+	}
+	if err := other.Refresh(); err != nil || !TagEqual(other.Current(), mustSNBT(t, "[3]")) {
+		t.Errorf("refresh: got %v, %v", other.Current(), err)
+	}
+	other.Cd(Int(0))
+	p.Delete()
+	if err := other.Refresh(); !errors.Is(err, PathStale) {
+		t.Errorf("refreshing stale path: expected PathStale, got %v", err)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	src := mustSNBT(t, `{a:{n:1,m:1},l:[1,2,3],keep:{x:1b}}`).(Compound)
+	d, err := NewDocument(src)
+	if err != nil {
+		t.Fatalf("new document: %s", err)
+	}
+	src["a"] = Int(0)
+	d2, err := d.Set(Int(5), "a", "n")
+	if err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	d2, err = d2.Delete("l", 1)
+	if err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	if _, err = d2.Set(String("x"), "l", 0); err == nil {
+		t.Errorf("setting String in Int list succeeded")
+	}
+	if _, err = d2.Delete("nope"); err == nil {
+		t.Errorf("deleting missing entry succeeded")
+	}
+	want := map[*Document]string{
+		d:  `{a:{m:1,n:1},keep:{x:1b},l:[1,2,3]}`,
+		d2: `{a:{m:1,n:5},keep:{x:1b},l:[1,3]}`,
+	}
+	for doc, s := range want {
+		if got := FormatSNBT(doc.Root()); got != s {
+			t.Errorf("expected %s, got %s", s, got)
+		}
+	}
+	k1, _ := d.Lookup("keep")
+	k2, _ := d2.Lookup("keep")
+	k1.(Compound)["shared"] = Byte(1)
+	if _, ok := k2.(Compound)["shared"]; !ok {
+		t.Errorf("untouched subtree wasn't shared between versions")
+	}
+
+	// readers should always see n and m equal
+	shared := &SharedDocument{}
+	shared.Store(d)
+	done := make(chan struct{})
+	errs := make(chan error, 4)
+	for r := 0; r < 4; r++ {
+		go func() {
+			defer func() { errs <- nil }()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				doc := shared.Load()
+				n, _ := Get[Int](doc.Root(), "a", "n")
+				m, _ := Get[Int](doc.Root(), "a", "m")
+				if n != m {
+					errs <- fmt.Errorf("inconsistent snapshot: n %d, m %d", n, m)
+					return
+				}
+			}
+		}()
+	}
+	for i := 2; i < 200; i++ {
+		err := shared.Update(func(doc *Document) (*Document, error) {
+			a, err := Get[Compound](doc.Root(), "a")
+			if err != nil {
+				return nil, err
+			}
+			a = TagCopy(a).(Compound)
+			a["n"], a["m"] = Int(i), Int(i)
+			return doc.Set(a, "a")
+		})
+		if err != nil {
+			t.Fatalf("update: %s", err)
+		}
+	}
+	close(done)
+	for r := 0; r < 4; r++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	const orig = `{a:{n:1},l:[1,2],b:[B;1b]}`
+	root := mustSNBT(t, orig)
+	s := NewSession(root)
+	steps := []func() error{
+		func() error { return s.Set(Int(5), String("a"), String("n")) },
+		func() error { return s.Set(String("new"), String("a"), String("s")) },
+		func() error { return s.Rename("m", String("a"), String("n")) },
+		func() error { return s.Insert(Int(9), String("l"), Int(1)) },
+		func() error { return s.Remove(String("l"), Int(0)) },
+		func() error { return s.Delete(String("b"), Int(0)) },
+	}
+	if err := s.Begin("first"); err != nil {
+		t.Fatalf("begin: %s", err)
+	}
+	for i, step := range steps {
+		if i == 3 {
+			if err := s.Commit(); err != nil {
+				t.Fatalf("commit: %s", err)
+			}
+		}
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %s", i, err)
+		}
+	}
+	if err := s.Set(String("x"), String("l"), Int(0)); err == nil {
+		t.Errorf("setting String in Int list succeeded")
+	}
+	const final = `{a:{m:5,s:"new"},b:[B;],l:[9,2]}`
+	if got := FormatSNBT(s.Root()); got != final {
+		t.Fatalf("after edits: expected %s, got %s", final, got)
+	}
+	patch, err := s.Patch().Tag()
+	if err != nil {
+		t.Fatalf("patch to tag: %s", err)
+	}
+	// four transactions: one named, three single edits
+	if len(s.History()) != 4 || s.History()[0].Name != "first" {
+		t.Errorf("unexpected history %v", s.History())
+	}
+	for i := 0; i < 4; i++ {
+		if err = s.Undo(); err != nil {
+			t.Fatalf("undo %d: %s", i, err)
+		}
+	}
+	if err = s.Undo(); err != SessionNothingToUndo {
+		t.Errorf("expected SessionNothingToUndo, got %v", err)
+	}
+	if !TagEqual(s.Root(), mustSNBT(t, orig)) {
+		t.Errorf("after undo: expected %s, got %s", orig, FormatSNBT(s.Root()))
+	}
+	for i := 0; i < 4; i++ {
+		if err = s.Redo(); err != nil {
+			t.Fatalf("redo %d: %s", i, err)
+		}
+	}
+	if got := FormatSNBT(s.Root()); got != final {
+		t.Errorf("after redo: expected %s, got %s", final, got)
+	}
+	s.Begin("abandoned")
+	s.Delete(String("a"))
+	if err = s.Rollback(); err != nil || FormatSNBT(s.Root()) != final {
+		t.Errorf("rollback: %v, got %s", err, FormatSNBT(s.Root()))
+	}
+
+	back, err := PatchFromTag(mustSNBT(t, FormatSNBT(patch)))
+	if err != nil {
+		t.Fatalf("patch from tag: %s", err)
+	}
+	other, err := back.Apply(mustSNBT(t, orig))
+	if err != nil {
+		t.Fatalf("apply patch: %s", err)
+	}
+	if got := FormatSNBT(other); got != final {
+		t.Errorf("patched copy: expected %s, got %s", final, got)
+	}
+}
+
+func TestSessionSubscribe(t *testing.T) {
+	s := NewSession(mustSNBT(t, `{a:{n:1},b:{m:2}}`))
+	var all, underA []Change
+	cancelAll := s.Subscribe(func(c Change) { all = append(all, c) })
+	s.Subscribe(func(c Change) { underA = append(underA, c) }, String("a"))
+	ch, cancelChan := s.SubscribeChan(10, String("b"), String("m"))
+	s.Set(Int(5), String("a"), String("n"))
+	s.Set(Int(6), String("b"), String("m"))
+	s.Delete(String("b"))
+	s.Undo()
+	cancelAll()
+	s.Rename("x", String("a"), String("n"))
+	cancelChan()
+	if len(all) != 4 {
+		t.Errorf("expected 4 changes, got %d", len(all))
+	}
+	if len(underA) != 2 || underA[1].Op != EditRename || underA[1].Key != "x" {
+		t.Errorf("unexpected changes under a: %v", underA)
+	}
+	if c := all[0]; c.Op != EditSet || c.Old != Int(1) || c.New != Int(5) {
+		t.Errorf("unexpected set change: %+v", c)
+	}
+	// the undo of the delete puts b back
+	if c := all[3]; c.Op != EditSet || c.Old != nil || !TagEqual(c.New, mustSNBT(t, "{m:6}")) {
+		t.Errorf("unexpected undo change: %+v", c)
+	}
+	var fromChan []Change
+	for c := range ch {
+		fromChan = append(fromChan, c)
+	}
+	if len(fromChan) != 3 || fromChan[1].Op != EditDelete {
+		t.Errorf("unexpected changes from channel: %v", fromChan)
+	}
+}
+
+func TestDocumentSetLazy(t *testing.T) {
+	d, err := NewDocument(Compound{})
+	if err != nil {
+		t.Fatalf("new document: %s", err)
+	}
+	d, err = d.Set(loadLazyBigtest(t), "big")
+	if err != nil {
+		t.Fatalf("set lazy value: %s", err)
+	}
+	Walk(d.Root(), func(p Path, tag Tag) error {
+		if c, ok := tag.(Compound); ok {
+			for k, v := range c {
+				if _, lazy := v.(*lazyTag); lazy {
+					t.Errorf("%v%s: still lazy in document", p, k)
+				}
+			}
+		}
+		return nil
+	})
+	bad := Compound{"x": &lazyTag{typ: TypeCompound, raw: []byte{byte(TypeInt), 0}}}
+	if _, err = d.Set(bad, "bad"); err == nil {
+		t.Errorf("setting undecodable lazy value succeeded")
+	}
+}
+
+func TestFailedUpdateUnchanged(t *testing.T) {
+	cases := []struct {
+		tree, path string
+		set        func(NBTPath, Tag) (Tag, int, error)
+	}{
+		{`{x:1}`, `a.b[0]`, func(np NBTPath, root Tag) (Tag, int, error) {
+			return np.Set(root, Int(1))
+		}},
+		{`{l:[{a:{}},{a:1}]}`, `l[].a`, func(np NBTPath, root Tag) (Tag, int, error) {
+			return np.Merge(root, Compound{"y": Int(1)})
+		}},
+	}
+	for _, c := range cases {
+		root := mustSNBT(t, c.tree)
+		out, _, err := c.set(MustParseNBTPath(c.path), root)
+		if err == nil {
+			t.Errorf("%s on %s: expected error", c.path, c.tree)
+		}
+		if got := FormatSNBT(root); got != c.tree {
+			t.Errorf("%s: failed update changed tree to %s", c.path, got)
+		}
+		if got := FormatSNBT(out); got != c.tree {
+			t.Errorf("%s: failed update returned %s", c.path, got)
+		}
+	}
+}
+
+func TestPatchFromLazyTag(t *testing.T) {
+	patch := Patch{
+		{Op: EditInsert, Path: []PathComponent{String("l"), Int(0)}, Value: MakeIntList([]Int{1})},
+		{Op: EditSet, Path: []PathComponent{String("c")}, Value: Compound{"d": IntArray{2}}},
+	}
+	pt, err := patch.Tag()
+	if err != nil {
+		t.Fatalf("patch tag: %s", err)
+	}
+	buf := &bytes.Buffer{}
+	if err = StoreUncompressed(buf, Compound{"patch": pt}, ""); err != nil {
+		t.Fatalf("store: %s", err)
+	}
+	lazy, _, err := LoadOptions{Lazy: true}.LoadUncompressed(buf)
+	if err != nil {
+		t.Fatalf("lazy load: %s", err)
+	}
+	lt, err := Get[List](lazy, "patch")
+	if err != nil {
+		t.Fatalf("get patch: %s", err)
+	}
+	back, err := PatchFromTag(lt)
+	if err != nil {
+		t.Fatalf("patch from tag: %s", err)
+	}
+	for i, e := range back {
+		if _, ok := e.Value.(*lazyTag); ok {
+			t.Errorf("edit %d: value still lazy", i)
+		}
+	}
+	got, err := back.Apply(mustSNBT(t, `{l:[[0]],c:1}`))
+	if err != nil {
+		t.Fatalf("apply: %s", err)
+	}
+	if want := `{c:{d:[I;2]},l:[[1],[0]]}`; FormatSNBT(got) != want {
+		t.Errorf("applied lazy patch: expected %s, got %s", want, FormatSNBT(got))
+	}
+}
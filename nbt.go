@@ -33,10 +33,12 @@ const (
 
 // A Tag is one of several concrete types which represent NBT "payloads",
 // because it turns out that's the correct conceptual entity to think of
-// as a "tag".
+// as a "tag". Other packages can implement Tag for custom tag types; see
+// RegisterTagType.
 type Tag interface {
 	Type() Type
-	store(w io.Writer) error
+	// Store writes the tag's payload, without its type or name.
+	Store(w io.Writer) error
 }
 
 type End struct{}
@@ -49,12 +51,13 @@ type Double float64
 type ByteArray []int8
 type String string
 
-// List is a pain to work with; see tag.tmp for the template code, and
-// typegen.go for the fancy generated-code implementations with type
-// switches.
+// List is a list of tags which are all of the Contents type. Internally
+// it holds a TypedList of that type; see list.go. For statically-typed
+// access, use AsTypedList and NewList, or the GetXList and MakeXList
+// functions.
 type List struct {
 	Contents Type
-	data     interface{}
+	data     listData
 }
 type Compound map[String]Tag
 type IntArray []Int
@@ -71,7 +74,7 @@ func (x End) String() string {
 
 // String() makes Byte objects printable.
 func (x Byte) String() string {
-	return fmt.Sprintf("%q", byte(x))
+	return strconv.FormatInt(int64(x), 10)
 }
 
 // String() makes Short objects printable.
@@ -111,6 +114,9 @@ func (x String) String() string {
 
 // String() makes List objects printable.
 func (x List) String() string {
+	if x.Mixed() {
+		return fmt.Sprintf("list[%d elements] of mixed types", x.Length())
+	}
 	return fmt.Sprintf("list[%d elements] of %v", x.Length(), x.Contents)
 }
 
@@ -129,67 +135,6 @@ func (x LongArray) String() string {
 	return fmt.Sprintf("%v [%d elements]", x.Type(), len(x))
 }
 
-// PrintIndented pretty-prints the given Tag.
-func PrintIndented(w io.Writer, t Tag) {
-	printIndented(w, t, nil, 0)
-}
-
-// printIndented tries to print the given tag,
-func printIndented(w io.Writer, p Tag, prefix interface{}, indent int) {
-	fmt.Fprintf(w, "%*s", indent*2, "")
-	switch v := prefix.(type) {
-	case nil:
-		// do nothing with a nil prefix
-	case string:
-		fmt.Fprintf(w, "%s: ", v)
-	case int:
-		fmt.Fprintf(w, "[%d]: ", v)
-	default:
-		fmt.Fprintf(w, "%s: ", v)
-	}
-	defer fmt.Fprintln(w) // newline after this
-	switch x := p.(type) {
-	default:
-		fmt.Fprintf(w, "[unknown tag %v]", p.Type())
-	case End:
-		fmt.Fprintf(w, "}")
-	case Byte:
-		fmt.Fprintf(w, "%q", x)
-	case Short:
-		fmt.Fprintf(w, "%d", x)
-	case Int:
-		fmt.Fprintf(w, "%d", x)
-	case Long:
-		fmt.Fprintf(w, "%d", x)
-	case Float:
-		fmt.Fprintf(w, "%f", x)
-	case Double:
-		fmt.Fprintf(w, "%f", x)
-	case String:
-		fmt.Fprintf(w, "%s", x)
-	case ByteArray:
-		fmt.Fprintf(w, "[%d item byte]", len(x))
-	case IntArray:
-		fmt.Fprintf(w, "[%d item int]", len(x))
-	case LongArray:
-		fmt.Fprintf(w, "[%d item long]", len(x))
-	case List:
-		length := x.Length()
-		fmt.Fprintf(w, "[%d %v list] {", length, x.Contents)
-		if length != 0 {
-			fmt.Fprintf(w, "\n")
-			x.Iterate(func(i int, t Tag) error { printIndented(w, p, i, indent+1); return nil })
-		}
-		fmt.Fprintf(w, "%*s}", indent*2, "")
-	case Compound:
-		fmt.Fprintf(w, "compound [%d elements] {\n", len(x))
-		for k, v := range x {
-			printIndented(w, v, k, indent+1)
-		}
-		fmt.Fprintf(w, "%*s}", indent*2, "")
-	}
-}
-
 func TagLength(t Tag) int {
 	switch tag := t.(type) {
 	case ByteArray:
@@ -206,8 +151,20 @@ func TagLength(t Tag) int {
 	return 0
 }
 
+// tagIndex converts an int or Int index to a plain int.
+func tagIndex(idx interface{}) (int, bool) {
+	switch i := idx.(type) {
+	case int:
+		return i, true
+	case Int:
+		return int(i), true
+	default:
+		return 0, false
+	}
+}
+
 // Element obtains the element t[idx], where idx is a string for a
-// Compound element, or an int for Array or List types.
+// Compound element, or an int (or Int) for Array or List types.
 func TagElement(t Tag, idx interface{}) (out Tag, ok bool) {
 	if t == nil {
 		return nil, false
@@ -223,17 +180,16 @@ func TagElement(t Tag, idx interface{}) (out Tag, ok bool) {
 			}
 			sidx = String(str)
 		}
-		pay, ok := tag[sidx]
-		return pay, ok
+		return tag.entry(sidx)
 	case List:
-		idx, ok := idx.(int)
+		idx, ok := tagIndex(idx)
 		if !ok {
 			return nil, false
 		}
 		data, ok := tag.Element(idx)
 		return data, ok
 	case ByteArray:
-		idx, ok := idx.(int)
+		idx, ok := tagIndex(idx)
 		if !ok {
 			return nil, false
 		}
@@ -242,7 +198,7 @@ func TagElement(t Tag, idx interface{}) (out Tag, ok bool) {
 		}
 		return nil, false
 	case IntArray:
-		idx, ok := idx.(int)
+		idx, ok := tagIndex(idx)
 		if !ok {
 			return nil, false
 		}
@@ -251,7 +207,7 @@ func TagElement(t Tag, idx interface{}) (out Tag, ok bool) {
 		}
 		return nil, false
 	case LongArray:
-		idx, ok := idx.(int)
+		idx, ok := tagIndex(idx)
 		if !ok {
 			return nil, false
 		}
@@ -264,6 +220,40 @@ func TagElement(t Tag, idx interface{}) (out Tag, ok bool) {
 	}
 }
 
+// TagCopy makes a deep copy of t, so that changes to the copy (or to t)
+// won't affect the other.
+func TagCopy(t Tag) Tag {
+	switch tag := t.(type) {
+	case Compound:
+		out := make(Compound, len(tag))
+		for k, v := range tag {
+			out[k] = TagCopy(v)
+		}
+		return out
+	case List:
+		elems := make([]Tag, 0, tag.Length())
+		tag.Iterate(func(_ int, t Tag) error {
+			elems = append(elems, TagCopy(t))
+			return nil
+		})
+		if tag.Mixed() {
+			return List{Contents: TypeCompound, data: mixedList{elems: elems}}
+		}
+		out, _ := makeListFromTags(tag.Contents, elems)
+		return out
+	case ByteArray:
+		return append(ByteArray{}, tag...)
+	case IntArray:
+		return append(IntArray{}, tag...)
+	case LongArray:
+		return append(LongArray{}, tag...)
+	case *lazyTag:
+		return tag.copy()
+	default:
+		return t
+	}
+}
+
 // HasElements indicates whether an item conceptually has sub-elements.
 func TagHasElements(t Tag) bool {
 	switch t.Type() {
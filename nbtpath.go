@@ -0,0 +1,446 @@
+package nbt
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// NBTPath is a parsed Minecraft NBT path, as used by commands like
+// `/data get`, such as `Inventory[{Slot:0b}].tag.display.Name`. Unlike a
+// Path, which is a single concrete location, an NBTPath can match any
+// number of nodes.
+type NBTPath struct {
+	text  string
+	nodes []nbtPathNode
+}
+
+// nbtPathNode is a single step of an NBTPath.
+type nbtPathNode interface {
+	// match appends to out every node this step selects starting from
+	// p's current tag.
+	match(p Path, out []Path) []Path
+	// update calls fn on every child of t this step selects, replacing
+	// the child with fn's result, and yields the possibly-new t. See
+	// modify.go.
+	update(t Tag, create bool, fn nbtPathUpdate) (Tag, int, error)
+	// parentDefault yields an empty tag of the kind this step expects to
+	// find, for creating missing parents.
+	parentDefault() Tag
+}
+
+// ParseNBTPath parses an NBT path, using the same grammar as Minecraft's
+// /data command: dotted keys (`a.b`), quoted keys (`"a b"`), indexes
+// (`[0]`, `[-1]`), all elements (`[]`), matching list elements
+// (`[{id:"minecraft:stone"}]`), matching named compounds (`a{b:1b}`),
+// and matching the root compound (`{b:1b}`).
+func ParseNBTPath(s string) (NBTPath, error) {
+	p := &snbtParser{s: s}
+	path := NBTPath{text: s}
+	if p.done() {
+		return path, p.errorf("empty NBT path")
+	}
+	for !p.done() {
+		node, err := p.parsePathNode(len(path.nodes) == 0)
+		if err != nil {
+			return path, err
+		}
+		path.nodes = append(path.nodes, node)
+		switch p.peek() {
+		case 0, '[', '{':
+		case '.':
+			p.pos++
+			if p.done() {
+				return path, p.errorf("expected key after '.'")
+			}
+		default:
+			return path, p.errorf("unexpected %q in NBT path", p.peek())
+		}
+	}
+	return path, nil
+}
+
+// MustParseNBTPath is like ParseNBTPath, but panics on error. It's
+// intended for paths which are constants in source code.
+func MustParseNBTPath(s string) NBTPath {
+	path, err := ParseNBTPath(s)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// String returns the text the path was parsed from.
+func (np NBTPath) String() string {
+	return np.text
+}
+
+// Match finds every node in root selected by the path, returning a
+// concrete Path to each of them. If nothing matches, the result is empty.
+func (np NBTPath) Match(root Tag) []Path {
+	current := []Path{NewPath(root)}
+	for _, node := range np.nodes {
+		var next []Path
+		for _, p := range current {
+			next = node.match(p, next)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// isUnquotedPathChar indicates whether c may appear in an unquoted key
+// in an NBT path, which is a lot more permissive than SNBT.
+func isUnquotedPathChar(c byte) bool {
+	switch c {
+	case ' ', '"', '\'', '[', ']', '.', '{', '}':
+		return false
+	}
+	return true
+}
+
+func (p *snbtParser) parsePathNode(first bool) (nbtPathNode, error) {
+	switch p.peek() {
+	case '{':
+		if !first {
+			return nil, p.errorf("compound filter only allowed at start of NBT path")
+		}
+		filter, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		return nbtPathRoot{filter: filter}, nil
+	case '[':
+		p.pos++
+		switch p.peek() {
+		case '{':
+			filter, err := p.parseCompound()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(']'); err != nil {
+				return nil, err
+			}
+			return nbtPathMatchElements{filter: filter}, nil
+		case ']':
+			p.pos++
+			return nbtPathAllElements{}, nil
+		}
+		start := p.pos
+		if p.peek() == '-' {
+			p.pos++
+		}
+		for p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+		idx, err := strconv.ParseInt(p.s[start:p.pos], 10, 32)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid index in NBT path")
+		}
+		if err = p.expect(']'); err != nil {
+			return nil, err
+		}
+		return nbtPathIndex{index: int(idx)}, nil
+	case '"', '\'':
+		key, err := p.readQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return p.parsePathKey(String(key))
+	}
+	start := p.pos
+	for !p.done() && isUnquotedPathChar(p.s[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.errorf("expected key in NBT path")
+	}
+	return p.parsePathKey(String(p.s[start:p.pos]))
+}
+
+// parsePathKey handles the optional compound filter after a key.
+func (p *snbtParser) parsePathKey(key String) (nbtPathNode, error) {
+	if p.peek() != '{' {
+		return nbtPathKey{key: key}, nil
+	}
+	filter, err := p.parseCompound()
+	if err != nil {
+		return nil, err
+	}
+	return nbtPathMatchKey{key: key, filter: filter}, nil
+}
+
+// nbtPathRoot matches the root tag, if it matches the filter.
+type nbtPathRoot struct {
+	filter Compound
+}
+
+func (n nbtPathRoot) match(p Path, out []Path) []Path {
+	if TagMatches(n.filter, p.Current()) {
+		out = append(out, p)
+	}
+	return out
+}
+
+// nbtPathKey matches a named entry in a compound.
+type nbtPathKey struct {
+	key String
+}
+
+func (n nbtPathKey) match(p Path, out []Path) []Path {
+	c, ok := p.Current().(Compound)
+	if !ok {
+		return out
+	}
+	if t, ok := c.entry(n.key); ok {
+		out = append(out, p.child(n.key, t))
+	}
+	return out
+}
+
+// nbtPathMatchKey matches a named entry in a compound, if it's a compound
+// which matches the filter.
+type nbtPathMatchKey struct {
+	key    String
+	filter Compound
+}
+
+func (n nbtPathMatchKey) match(p Path, out []Path) []Path {
+	c, ok := p.Current().(Compound)
+	if !ok {
+		return out
+	}
+	if t, ok := c.entry(n.key); ok && TagMatches(n.filter, t) {
+		out = append(out, p.child(n.key, t))
+	}
+	return out
+}
+
+// nbtPathIndex matches a single element of a list or array. Negative
+// indexes count from the end.
+type nbtPathIndex struct {
+	index int
+}
+
+func (n nbtPathIndex) match(p Path, out []Path) []Path {
+	t := p.Current()
+	if !tagIsIndexable(t) {
+		return out
+	}
+	idx := n.index
+	if idx < 0 {
+		idx += TagLength(t)
+	}
+	if elt, ok := TagElement(t, idx); ok {
+		out = append(out, p.child(Int(idx), elt))
+	}
+	return out
+}
+
+// nbtPathAllElements matches every element of a list or array.
+type nbtPathAllElements struct{}
+
+func (n nbtPathAllElements) match(p Path, out []Path) []Path {
+	t := p.Current()
+	if !tagIsIndexable(t) {
+		return out
+	}
+	for i := 0; i < TagLength(t); i++ {
+		if elt, ok := TagElement(t, i); ok {
+			out = append(out, p.child(Int(i), elt))
+		}
+	}
+	return out
+}
+
+// nbtPathMatchElements matches every element of a list which matches
+// the filter.
+type nbtPathMatchElements struct {
+	filter Compound
+}
+
+func (n nbtPathMatchElements) match(p Path, out []Path) []Path {
+	l, ok := p.Current().(List)
+	if !ok || l.Contents != TypeCompound {
+		return out
+	}
+	l.Iterate(func(i int, t Tag) error {
+		if TagMatches(n.filter, t) {
+			out = append(out, p.child(Int(i), t))
+		}
+		return nil
+	})
+	return out
+}
+
+// tagIsIndexable indicates whether t is a List or one of the array types,
+// which can be indexed by integers.
+func tagIsIndexable(t Tag) bool {
+	if t == nil {
+		return false
+	}
+	switch t.Type() {
+	case TypeList, TypeByteArray, TypeIntArray, TypeLongArray:
+		return true
+	default:
+		return false
+	}
+}
+
+// TagMatches reports whether t matches pattern, using Minecraft's rules
+// for NBT path filters: a compound matches if every key in the pattern is
+// present in t and matches, a list matches if every element of the pattern
+// matches some element of t (and an empty pattern list matches only an
+// empty list), and anything else must be equal.
+func TagMatches(pattern, t Tag) bool {
+	if pattern == nil {
+		return true
+	}
+	if t == nil || pattern.Type() != t.Type() {
+		return false
+	}
+	switch pat := pattern.(type) {
+	case Compound:
+		c, ok := t.(Compound)
+		if !ok {
+			// an undecodable lazy entry
+			return false
+		}
+		for k, v := range pat {
+			have, _ := c.entry(k)
+			if !TagMatches(v, have) {
+				return false
+			}
+		}
+		return true
+	case List:
+		l, ok := t.(List)
+		if !ok {
+			return false
+		}
+		if pat.Length() == 0 {
+			return l.Length() == 0
+		}
+		err := pat.Iterate(func(_ int, want Tag) error {
+			found := l.Iterate(func(_ int, have Tag) error {
+				if TagMatches(want, have) {
+					return errFound
+				}
+				return nil
+			})
+			if found != errFound {
+				return errNotFound
+			}
+			return nil
+		})
+		return err == nil
+	default:
+		return TagEqual(pattern, t)
+	}
+}
+
+// sentinel errors used to stop iterations early
+var (
+	errFound    = fmt.Errorf("found")
+	errNotFound = fmt.Errorf("not found")
+)
+
+// TagEqual reports whether a and b are the same type and have the same
+// contents, recursively.
+func TagEqual(a, b Tag) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch x := a.(type) {
+	case Compound:
+		y, ok := b.(Compound)
+		if !ok {
+			// an undecodable lazy entry
+			return false
+		}
+		if len(x) != len(y) {
+			return false
+		}
+		for k := range x {
+			v, _ := x.entry(k)
+			other, _ := y.entry(k)
+			if !TagEqual(v, other) {
+				return false
+			}
+		}
+		return true
+	case List:
+		y, ok := b.(List)
+		if !ok {
+			return false
+		}
+		if x.Contents != y.Contents || x.Length() != y.Length() {
+			return false
+		}
+		if x.Length() == 0 {
+			return true
+		}
+		err := x.Iterate(func(i int, t Tag) error {
+			other, _ := y.Element(i)
+			if !TagEqual(t, other) {
+				return errNotFound
+			}
+			return nil
+		})
+		return err == nil
+	case ByteArray:
+		y, ok := b.(ByteArray)
+		if !ok {
+			return false
+		}
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	case IntArray:
+		y, ok := b.(IntArray)
+		if !ok {
+			return false
+		}
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	case LongArray:
+		y, ok := b.(LongArray)
+		if !ok {
+			return false
+		}
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	case Byte, Short, Int, Long, Float, Double, String, End:
+		return a == b
+	default:
+		// custom types might not be comparable
+		return reflect.DeepEqual(a, b)
+	}
+}
@@ -0,0 +1,254 @@
+package nbt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func mustReadBigtest(t testing.TB) []byte {
+	bigtest, err := ioutil.ReadFile("examples/bigtest.nbt")
+	if err != nil {
+		t.Fatalf("couldn't open bigtest.nbt: %s", err)
+	}
+	return bigtest
+}
+
+func loadBigtest(t *testing.T) Tag {
+	tag, _, err := Load(bytes.NewBuffer(mustReadBigtest(t)))
+	if err != nil {
+		t.Fatalf("couldn't read sample data: %s", err)
+	}
+	return tag
+}
+
+func TestNBTPathMatch(t *testing.T) {
+	root := loadBigtest(t)
+	cases := []struct {
+		path  string
+		count int
+		last  Tag
+		where string
+	}{
+		{"intTest", 1, Int(2147483647), "intTest/"},
+		{`"nested compound test".egg.name`, 1, String("Eggbert"), "nested compound test/egg/name/"},
+		{"'nested compound test'.ham{name:\"Hampus\"}.value", 1, Float(0.75), "nested compound test/ham/value/"},
+		{"'nested compound test'.ham{name:\"Eggbert\"}", 0, nil, ""},
+		{`"listTest (long)"[0]`, 1, Long(11), "listTest (long)/0/"},
+		{`"listTest (long)"[-1]`, 1, Long(15), "listTest (long)/4/"},
+		{`"listTest (long)"[5]`, 0, nil, ""},
+		{`"listTest (long)"[]`, 5, Long(15), "listTest (long)/4/"},
+		{`"listTest (compound)"[{name:"Compound tag #1"}].name`, 1, String("Compound tag #1"), "listTest (compound)/1/name/"},
+		{`"listTest (compound)"[].name`, 2, String("Compound tag #1"), "listTest (compound)/1/name/"},
+		{"{shortTest:32767s}.shortTest", 1, Short(32767), "shortTest/"},
+		{"{shortTest:32767}.shortTest", 0, nil, ""},
+		{`"byteArrayTest (the first 1000 values of (n*n*255+n*7)%100, starting with n=0 (0, 62, 34, 16, 8, ...))"[1]`, 1, Byte(62), ""},
+	}
+	for _, c := range cases {
+		path, err := ParseNBTPath(c.path)
+		if err != nil {
+			t.Errorf("parse %q: unexpected error %s", c.path, err)
+			continue
+		}
+		found := path.Match(root)
+		if len(found) != c.count {
+			t.Errorf("%q: expected %d matches, got %d", c.path, c.count, len(found))
+			continue
+		}
+		if c.count == 0 {
+			continue
+		}
+		last := found[len(found)-1]
+		if !TagEqual(last.Current(), c.last) {
+			t.Errorf("%q: expected %v, got %v", c.path, c.last, last.Current())
+		}
+		if c.where != "" && last.String() != c.where {
+			t.Errorf("%q: expected path %q, got %q", c.path, c.where, last.String())
+		}
+	}
+}
+
+func TestNBTPathParseErrors(t *testing.T) {
+	bad := []string{
+		"",
+		"a.",
+		"a..b",
+		"a[",
+		"a[x]",
+		"a[0]b",
+		"a.{b:1}",
+		`"unterminated`,
+		"a{b:}",
+	}
+	for _, s := range bad {
+		if _, err := ParseNBTPath(s); err == nil {
+			t.Errorf("%q: expected parse error, got none", s)
+		}
+	}
+}
+
+func TestParseSNBT(t *testing.T) {
+	cases := []struct {
+		in  string
+		out Tag
+	}{
+		{"1b", Byte(1)},
+		{"true", Byte(1)},
+		{"-3s", Short(-3)},
+		{"12", Int(12)},
+		{"12L", Long(12)},
+		{"1.5f", Float(1.5)},
+		{"1.5", Double(1.5)},
+		{"2d", Double(2)},
+		{"foo", String("foo")},
+		{`'it\'s'`, String("it's")},
+		{"[I;1,2]", IntArray{1, 2}},
+		{"[B;]", ByteArray{}},
+		{"[1,2,3]", MakeIntList([]Int{1, 2, 3})},
+		{"[1,2b]", mustMixed(t, Int(1), Byte(2))},
+		{`{a:1b, "b c":[{}]}`, Compound{"a": Byte(1), "b c": MakeCompoundList([]Compound{{}})}},
+	}
+	for _, c := range cases {
+		got, err := ParseSNBT(c.in)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", c.in, err)
+			continue
+		}
+		if !TagEqual(got, c.out) {
+			t.Errorf("%q: expected %v, got %v", c.in, c.out, got)
+		}
+	}
+	for _, s := range []string{"[I;1b]", "{a:1", "300b", "[X;1]"} {
+		if _, err := ParseSNBT(s); err == nil {
+			t.Errorf("%q: expected parse error, got none", s)
+		}
+	}
+}
+
+func TestFormatSNBT(t *testing.T) {
+	root := loadBigtest(t)
+	text := FormatSNBT(root)
+	back, err := ParseSNBT(text)
+	if err != nil {
+		t.Fatalf("parsing formatted SNBT: %s", err)
+	}
+	if !TagEqual(root, back) {
+		t.Fatalf("SNBT round trip didn't match: %s", text)
+	}
+	if got := FormatSNBT(Compound{"a b": String(`it's "x"`), "c": Float(0.5)}); got != `{"a b":"it's \"x\"",c:0.5f}` {
+		t.Errorf("unexpected SNBT: %s", got)
+	}
+}
+
+func TestLoadPaths(t *testing.T) {
+	bigtest := mustReadBigtest(t)
+	cases := []struct {
+		paths []string
+		want  string
+	}{
+		{[]string{"intTest", `"nested compound test".egg.name`}, `{intTest:2147483647,"nested compound test":{egg:{name:"Eggbert"}}}`},
+		{[]string{`"listTest (long)"[-1]`, "noSuchKey"}, `{"listTest (long)":[15L]}`},
+		{[]string{`"listTest (compound)"[].name`}, `{"listTest (compound)":[{name:"Compound tag #0"},{name:"Compound tag #1"}]}`},
+		{[]string{`"nested compound test".ham{name:"x"}.value`}, `{"nested compound test":{ham:{name:"Hampus",value:0.75f}}}`},
+		{[]string{"intTest.nope", "shortTest[0]"}, `{}`},
+	}
+	for _, c := range cases {
+		paths := make([]NBTPath, len(c.paths))
+		for i, p := range c.paths {
+			paths[i] = MustParseNBTPath(p)
+		}
+		got, name, err := LoadPaths(bytes.NewReader(bigtest), paths...)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", c.paths, err)
+			continue
+		}
+		if name != "Level" {
+			t.Errorf("%q: expected name Level, got %q", c.paths, name)
+		}
+		if s := FormatSNBT(got); s != c.want {
+			t.Errorf("%q: expected %s, got %s", c.paths, c.want, s)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	root, _, ix, err := LoadIndexed(bytes.NewReader(mustReadBigtest(t)))
+	if err != nil {
+		t.Fatalf("load: %s", err)
+	}
+	count := 0
+	Walk(root, func(Path, Tag) error { count++; return nil })
+	if len(ix.Entries) != count {
+		t.Errorf("expected %d index entries, got %d", count, len(ix.Entries))
+	}
+	if e := ix.Entries[0]; e.Offset != 0 || e.HeaderLen != 8 {
+		t.Errorf("root entry: unexpected %+v", e)
+	}
+	buf := &bytes.Buffer{}
+	if err = StoreUncompressed(buf, root, "Level"); err != nil {
+		t.Fatalf("store: %s", err)
+	}
+	data := bytes.NewReader(buf.Bytes())
+	ix, err = BuildIndex(data)
+	if err != nil {
+		t.Fatalf("index: %s", err)
+	}
+	for _, path := range []string{`"nested compound test".egg`, `"listTest (compound)"[1].name`, "intTest"} {
+		p := MustParseNBTPath(path).Match(root)[0]
+		e, ok := ix.Find(p)
+		if !ok {
+			t.Errorf("%s: no index entry", path)
+			continue
+		}
+		got, err := LoadEntry(data, e)
+		if err != nil {
+			t.Errorf("%s: load entry: %s", path, err)
+			continue
+		}
+		if !TagEqual(got, p.Current()) {
+			t.Errorf("%s: expected %s, got %s", path, FormatSNBT(p.Current()), FormatSNBT(got))
+		}
+	}
+	ix, err = BuildIndex(bytes.NewReader(buf.Bytes()[:buf.Len()/2]))
+	if err == nil || len(ix.Entries) == 0 {
+		t.Errorf("truncated data: expected partial index and error, got %d entries, %v", len(ix.Entries), err)
+	}
+	// elements of mixed lists are indexed without their wrappers
+	mixed := Compound{"m": mustMixed(t, Int(1), Compound{"a": Byte(2)}, Compound{"": Compound{"b": Short(3)}}, String("x"))}
+	buf.Reset()
+	if err = StoreUncompressed(buf, mixed, ""); err != nil {
+		t.Fatalf("store mixed: %s", err)
+	}
+	data = bytes.NewReader(buf.Bytes())
+	root, _, ix, err = LoadIndexed(data)
+	if err != nil {
+		t.Fatalf("load mixed: %s", err)
+	}
+	count = 0
+	Walk(root, func(Path, Tag) error { count++; return nil })
+	if len(ix.Entries) != count {
+		t.Errorf("mixed: expected %d index entries, got %d", count, len(ix.Entries))
+	}
+	for _, path := range []string{"m[0]", "m[1].a", `m[2].""`, `m[2]."".b`, "m[3]"} {
+		p := MustParseNBTPath(path).Match(root)[0]
+		e, ok := ix.Find(p)
+		if !ok {
+			t.Errorf("mixed %s: no index entry", path)
+			continue
+		}
+		got, err := LoadEntry(data, e)
+		if err != nil || !TagEqual(got, p.Current()) {
+			t.Errorf("mixed %s: expected %s, got %v, %v", path, FormatSNBT(p.Current()), got, err)
+		}
+	}
+	if _, ok := ix.Lookup(String("m"), Int(0), String("")); ok {
+		t.Errorf("mixed: found entry for wrapper component")
+	}
+	_, _, ix, err = LoadOptions{KeepWrappedLists: true}.LoadIndexed(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("load mixed wrapped: %s", err)
+	}
+	if e, ok := ix.Lookup(String("m"), Int(0), String("")); !ok || e.Type != TypeInt {
+		t.Errorf("mixed wrapped: expected Int entry under wrapper, got %+v, %t", e, ok)
+	}
+}
@@ -0,0 +1,151 @@
+package nbt
+
+import (
+	"sync"
+)
+
+// Notifications of changes made through a Session, so that views of a
+// document can be kept up to date without comparing whole trees.
+
+// Change describes a single change made to a Session's tree. Old is the
+// tag which was replaced or removed, and New the tag which was set or
+// inserted; either may be nil. For a rename, Path is the entry's old path,
+// and Key its new key. Old and New must not be modified.
+type Change struct {
+	Op       EditOp
+	Path     []PathComponent
+	Old, New Tag
+	Key      String
+}
+
+// makeChange describes the effect of e, given the edit which undoes it.
+func makeChange(e, undo Edit) Change {
+	c := Change{Op: e.Op, Path: e.Path, Key: e.Key}
+	switch e.Op {
+	case EditSet, EditInsert:
+		c.New = e.Value
+	}
+	switch undo.Op {
+	case EditSet, EditInsert:
+		c.Old = undo.Value
+	}
+	return c
+}
+
+// affects indicates whether a change at path affects anything under
+// prefix, which is true if either one contains the other.
+func affects(path, prefix []PathComponent) bool {
+	n := len(path)
+	if len(prefix) < n {
+		n = len(prefix)
+	}
+	for i := 0; i < n; i++ {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// subscription is a single subscriber, which has either a function or
+// a channel.
+type subscription struct {
+	prefix []PathComponent
+	fn     func(Change)
+
+	mu     sync.Mutex
+	ch     chan Change
+	done   chan struct{}
+	closed bool
+}
+
+// deliver sends c to the subscriber.
+func (sub *subscription) deliver(c Change) {
+	if sub.fn != nil {
+		sub.fn(c)
+		return
+	}
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return
+	}
+	select {
+	case sub.ch <- c:
+	case <-sub.done:
+	}
+}
+
+// observers is the set of subscribers to a Session.
+type observers struct {
+	mu   sync.Mutex
+	subs []*subscription
+}
+
+func (o *observers) add(sub *subscription) func() {
+	o.mu.Lock()
+	o.subs = append(o.subs, sub)
+	o.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() { o.remove(sub) })
+	}
+}
+
+func (o *observers) remove(sub *subscription) {
+	o.mu.Lock()
+	for i, s := range o.subs {
+		if s == sub {
+			o.subs = append(o.subs[:i:i], o.subs[i+1:]...)
+			break
+		}
+	}
+	o.mu.Unlock()
+	if sub.ch != nil {
+		// unblock a pending delivery before closing the channel
+		close(sub.done)
+		sub.mu.Lock()
+		sub.closed = true
+		close(sub.ch)
+		sub.mu.Unlock()
+	}
+}
+
+// notify tells the interested subscribers about e.
+func (o *observers) notify(e, undo Edit) {
+	o.mu.Lock()
+	subs := o.subs
+	o.mu.Unlock()
+	if len(subs) == 0 {
+		return
+	}
+	c := makeChange(e, undo)
+	for _, sub := range subs {
+		if affects(c.Path, sub.prefix) {
+			sub.deliver(c)
+		}
+	}
+}
+
+// Subscribe calls fn for every change made through s which affects the
+// tree under prefix, meaning changes to prefix itself, to anything under
+// it, or to anything containing it. An empty prefix gets every change.
+// Changes from Undo, Redo, and Rollback are included. fn is called after
+// each change is made, and must not edit s. The returned function cancels
+// the subscription.
+func (s *Session) Subscribe(fn func(Change), prefix ...PathComponent) (cancel func()) {
+	sub := &subscription{prefix: append([]PathComponent{}, prefix...), fn: fn}
+	return s.observers.add(sub)
+}
+
+// SubscribeChan is like Subscribe, but delivers changes on a channel with
+// the given buffer size. If the buffer is full, edits wait until there's
+// room, or the subscription is cancelled, which closes the channel.
+func (s *Session) SubscribeChan(buffer int, prefix ...PathComponent) (<-chan Change, func()) {
+	sub := &subscription{
+		prefix: append([]PathComponent{}, prefix...),
+		ch:     make(chan Change, buffer),
+		done:   make(chan struct{}),
+	}
+	return sub.ch, s.observers.add(sub)
+}
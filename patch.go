@@ -0,0 +1,134 @@
+package nbt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Patching values in uncompressed data without loading and storing the
+// whole document.
+
+var (
+	PatchSizeChanged  = errors.New("encoded size would change")
+	PatchTypeMismatch = errors.New("value has wrong type")
+)
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r   io.Reader
+	pos int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.pos += int64(n)
+	return n, err
+}
+
+// seekEntry reads compound entries until it finds the one named key, and
+// yields its type.
+func seekEntry(r io.Reader, key String) (Type, error) {
+	for {
+		typ, name, err := loadHeader(r)
+		if err != nil {
+			return typ, err
+		}
+		if typ == TypeEnd {
+			return typ, PathNoEntry(key)
+		}
+		if name == key {
+			return typ, nil
+		}
+		if err = skipPayload(r, typ); err != nil {
+			return typ, err
+		}
+	}
+}
+
+// seekElement reads up to element i of a list or array, and yields its
+// type.
+func seekElement(r io.Reader, typ Type, i Int) (Type, error) {
+	if typ == TypeList {
+		elemType, err := loadByte(r)
+		if err != nil {
+			return TypeEnd, err
+		}
+		typ = Type(elemType)
+	} else {
+		typ = arrayElementType(typ)
+	}
+	count, err := loadInt(r)
+	if err != nil {
+		return typ, err
+	}
+	if i < 0 || i >= count {
+		return typ, PathNoIndex(i)
+	}
+	if typ < TypeMax && payloadSizes[typ] != 0 {
+		return typ, skip(r, int64(i)*payloadSizes[typ])
+	}
+	for j := Int(0); j < i; j++ {
+		if err = skipPayload(r, typ); err != nil {
+			return typ, err
+		}
+	}
+	return typ, nil
+}
+
+// PatchInPlace overwrites the value at path in the uncompressed document
+// starting at rw's current position with value, without touching the rest
+// of the document. Only path's Components are used. The value must be of
+// the same type as the one it replaces, and encode to the same number of
+// bytes, which is always true of numbers, but not of strings or lists.
+func PatchInPlace(rw io.ReadWriteSeeker, path Path, value Tag) error {
+	start, err := rw.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	cr := &countingReader{r: bufio.NewReader(rw)}
+	typ, _, err := loadHeader(cr)
+	if err != nil {
+		return err
+	}
+	for _, comp := range path.Components {
+		switch c := comp.(type) {
+		case String:
+			if typ != TypeCompound {
+				return fmt.Errorf("%v can't be indexed by %T %q", typ, c, c)
+			}
+			typ, err = seekEntry(cr, c)
+		case Int:
+			if typ != TypeList && arrayElementType(typ) == TypeEnd {
+				return fmt.Errorf("%v can't be indexed by %T %d", typ, c, c)
+			}
+			typ, err = seekElement(cr, typ, c)
+		default:
+			return fmt.Errorf("unsupported path component %T", comp)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if value == nil || value.Type() != typ {
+		return fmt.Errorf("patching %v at %s: %w", typ, path, PatchTypeMismatch)
+	}
+	offset := cr.pos
+	if err = skipPayload(cr, typ); err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	if err = value.Store(buf); err != nil {
+		return err
+	}
+	if size := cr.pos - offset; int64(buf.Len()) != size {
+		return fmt.Errorf("patching %s: %d bytes stored, new value is %d: %w", path, size, buf.Len(), PatchSizeChanged)
+	}
+	if _, err = rw.Seek(start+offset, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = rw.Write(buf.Bytes())
+	return err
+}
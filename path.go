@@ -23,6 +23,8 @@ type PathComponent interface {
 var (
 	PathAboveRoot    = errors.New("cannot move above root")
 	PathNilComponent = errors.New("cannot have nil component")
+	PathAtRoot       = errors.New("path is at root")
+	PathStale        = errors.New("path no longer matches tree")
 )
 
 func PathWrongType(t Tag, idx interface{}) error {
@@ -89,7 +91,6 @@ func (p *Path) Cd(comp PathComponent) (Tag, error) {
 	if err != nil {
 		return newTag, err
 	}
-	fmt.Printf("cd success: %v\n", newTag)
 	// append the requested items to this path
 	p.Tags = append(p.Tags, newTag)
 	p.Components = append(p.Components, comp)
@@ -117,7 +118,209 @@ func (p Path) String() string {
 	return buf.String()
 }
 
+// child yields a new path extending p with the given component and tag,
+// which does not share storage with p.
+func (p Path) child(comp PathComponent, t Tag) Path {
+	out := Path{
+		Tags:       make([]Tag, len(p.Tags), len(p.Tags)+1),
+		Components: make([]PathComponent, len(p.Components), len(p.Components)+1),
+	}
+	copy(out.Tags, p.Tags)
+	copy(out.Components, p.Components)
+	out.Tags = append(out.Tags, t)
+	out.Components = append(out.Components, comp)
+	return out
+}
+
 // NewPath creates a new Path rooted in the given tag.
 func NewPath(t Tag) Path {
 	return Path{Tags: []Tag{t}}
 }
+
+// Root yields the root tag of the path. After edits through the path, it
+// may be a different value than the path was created with, if the root
+// is a List or array.
+func (p Path) Root() Tag {
+	return p.Tags[0]
+}
+
+// componentIndex yields the list or array index comp represents, allowing
+// indexes which were passed in as strings, like Follow does.
+func componentIndex(comp PathComponent) (int, bool) {
+	switch c := comp.(type) {
+	case Int:
+		return int(c), true
+	case String:
+		i, err := strconv.ParseInt(string(c), 10, 32)
+		return int(i), err == nil
+	}
+	return 0, false
+}
+
+// setChild stores child in parent, at the location given by comp. It
+// yields the updated parent, which for Lists and arrays may be a new
+// value. The child must already exist; this doesn't add things.
+func setChild(parent Tag, comp PathComponent, child Tag) (Tag, error) {
+	if c, ok := parent.(Compound); ok {
+		key, ok := comp.(String)
+		if !ok {
+			return parent, PathWrongType(parent, comp)
+		}
+		c[key] = child
+		return c, nil
+	}
+	i, ok := componentIndex(comp)
+	if !ok {
+		return parent, PathWrongType(parent, comp)
+	}
+	if i < 0 || i >= TagLength(parent) {
+		return parent, PathNoIndex(Int(i))
+	}
+	switch x := parent.(type) {
+	case List:
+		return x.Set(i, child)
+	case ByteArray:
+		if b, ok := child.(Byte); ok {
+			x[i] = int8(b)
+			return x, nil
+		}
+	case IntArray:
+		if v, ok := child.(Int); ok {
+			x[i] = v
+			return x, nil
+		}
+	case LongArray:
+		if v, ok := child.(Long); ok {
+			x[i] = v
+			return x, nil
+		}
+	default:
+		return parent, PathWrongType(parent, comp)
+	}
+	return parent, fmt.Errorf("can't store %v in %v", child.Type(), parent.Type())
+}
+
+// propagate stores each tag in the path, starting with Tags[i], in its
+// parent, up to the root. A Compound is updated in place, but a List or
+// array in the path may be a copy of the one its parent holds, so every
+// level gets updated.
+func (p *Path) propagate(i int) error {
+	for ; i > 0; i-- {
+		parent, err := setChild(p.Tags[i-1], p.Components[i-1], p.Tags[i])
+		if err != nil {
+			return err
+		}
+		p.Tags[i-1] = parent
+	}
+	return nil
+}
+
+// Set replaces the path's current tag with t, updating its parents up to
+// the root. Elements of Lists and arrays have to keep their types. Setting
+// the root just replaces it.
+func (p *Path) Set(t Tag) error {
+	if t == nil {
+		return fmt.Errorf("can't set nil tag")
+	}
+	last := len(p.Tags) - 1
+	if last > 0 {
+		parent, err := setChild(p.Tags[last-1], p.Components[last-1], t)
+		if err != nil {
+			return err
+		}
+		p.Tags[last-1] = parent
+	}
+	p.Tags[last] = t
+	return p.propagate(last - 1)
+}
+
+// Delete removes the path's current tag from its parent, updating the
+// parents up to the root, and moves the path up to the parent. Deleting
+// an element of a List or array shifts the elements after it, so other
+// Paths to those elements need a Refresh.
+func (p *Path) Delete() error {
+	last := len(p.Components)
+	if last == 0 {
+		return PathAtRoot
+	}
+	parent, comp := p.Tags[last-1], p.Components[last-1]
+	if c, ok := parent.(Compound); ok {
+		key, ok := comp.(String)
+		if !ok {
+			return PathWrongType(parent, comp)
+		}
+		delete(c, key)
+	} else {
+		i, ok := componentIndex(comp)
+		if !ok || !tagIsIndexable(parent) {
+			return PathWrongType(parent, comp)
+		}
+		if i < 0 || i >= TagLength(parent) {
+			return PathNoIndex(Int(i))
+		}
+		switch x := parent.(type) {
+		case List:
+			l, err := x.Remove(i)
+			if err != nil {
+				return err
+			}
+			parent = l
+		case ByteArray:
+			parent = append(x[:i:i], x[i+1:]...)
+		case IntArray:
+			parent = append(x[:i:i], x[i+1:]...)
+		case LongArray:
+			parent = append(x[:i:i], x[i+1:]...)
+		}
+	}
+	p.Tags = p.Tags[:last]
+	p.Components = p.Components[:last-1]
+	p.Tags[last-1] = parent
+	return p.propagate(last - 1)
+}
+
+// Rename changes the key of the path's current tag, which must be an entry
+// in a Compound, to key. There mustn't already be an entry named key.
+func (p *Path) Rename(key String) error {
+	last := len(p.Components)
+	if last == 0 {
+		return PathAtRoot
+	}
+	c, ok := p.Tags[last-1].(Compound)
+	if !ok {
+		return fmt.Errorf("can't rename element of %v", p.Tags[last-1].Type())
+	}
+	old, ok := p.Components[last-1].(String)
+	if !ok {
+		return PathWrongType(c, p.Components[last-1])
+	}
+	if old == key {
+		return nil
+	}
+	if _, exists := c[key]; exists {
+		return fmt.Errorf("can't rename %q: entry %q already exists", old, key)
+	}
+	c[key] = c[old]
+	delete(c, old)
+	p.Components[last-1] = key
+	// c may have been decoded from a lazy entry
+	return p.propagate(last - 1)
+}
+
+// Refresh follows the path's components from its root again, updating
+// the tags along the way, which may be out of date if the tree was edited
+// through another Path. If a component can no longer be followed, the
+// path is left alone and the error wraps PathStale.
+func (p *Path) Refresh() error {
+	tags := make([]Tag, 1, len(p.Tags))
+	tags[0] = p.Tags[0]
+	for _, comp := range p.Components {
+		next, err := comp.Follow(tags[len(tags)-1])
+		if err != nil {
+			return fmt.Errorf("%w: %v", PathStale, err)
+		}
+		tags = append(tags, next)
+	}
+	p.Tags = tags
+	return nil
+}
@@ -0,0 +1,211 @@
+package nbt
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Human-readable printing of tag trees, one node per line.
+
+// Printer controls how tags are printed. The zero value prints with no
+// indentation, keys in map order, and arrays summarized by their sizes.
+type Printer struct {
+	// Indent is written once per level of nesting.
+	Indent string
+	// SortKeys prints Compound entries in order of their keys.
+	SortKeys bool
+	// MaxDepth, if positive, limits how many levels of nesting are
+	// printed; deeper containers are summarized.
+	MaxDepth int
+	// ArrayPreview is how many elements of each array to print. If it's
+	// negative, all of them are printed.
+	ArrayPreview int
+	// Hex prints array elements in hexadecimal.
+	Hex bool
+	// Color uses ANSI escape sequences to color values by type.
+	Color bool
+	// ExactFloats prints Float and Double values with as many digits as
+	// it takes to represent them exactly, rather than using %f.
+	ExactFloats bool
+}
+
+// DefaultPrinter is the Printer used by PrintIndented.
+var DefaultPrinter = Printer{Indent: "  ", SortKeys: true, ExactFloats: true}
+
+// PrintIndented pretty-prints the given Tag using DefaultPrinter.
+func PrintIndented(w io.Writer, t Tag) {
+	DefaultPrinter.Print(w, t)
+}
+
+// ANSI colors used for each kind of output.
+const (
+	colorReset     = "\x1b[0m"
+	colorKey       = "\x1b[34m"
+	colorInteger   = "\x1b[36m"
+	colorFloat     = "\x1b[35m"
+	colorString    = "\x1b[32m"
+	colorContainer = "\x1b[33m"
+)
+
+// printWriter writes formatted output, keeping the first error.
+type printWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (pw *printWriter) printf(format string, args ...interface{}) {
+	if pw.err == nil {
+		_, pw.err = fmt.Fprintf(pw.w, format, args...)
+	}
+}
+
+// colored writes s in the given color, if colors are enabled.
+func (p Printer) colored(pw *printWriter, color, s string) {
+	if p.Color {
+		pw.printf("%s%s%s", color, s, colorReset)
+		return
+	}
+	pw.printf("%s", s)
+}
+
+// Print prints t to w, followed by a newline, and reports the first write
+// error, if any.
+func (p Printer) Print(w io.Writer, t Tag) error {
+	pw := &printWriter{w: w}
+	p.print(pw, t, nil, 0)
+	return pw.err
+}
+
+// formatFloat formats a Float or Double of the given bit size.
+func (p Printer) formatFloat(f float64, bits int) string {
+	if p.ExactFloats {
+		return strconv.FormatFloat(f, 'g', -1, bits)
+	}
+	return fmt.Sprintf("%f", f)
+}
+
+// arrayPreview formats the first elements of an array, as permitted by
+// ArrayPreview, using elem to format each of them.
+func (p Printer) arrayPreview(n int, elem func(i int) string) string {
+	count := p.ArrayPreview
+	if count < 0 || count > n {
+		count = n
+	}
+	if count == 0 {
+		return ""
+	}
+	parts := make([]string, count, count+1)
+	for i := range parts {
+		parts[i] = elem(i)
+	}
+	if count < n {
+		parts = append(parts, "...")
+	}
+	return " " + strings.Join(parts, " ")
+}
+
+// printArray prints an array's summary and preview.
+func (p Printer) printArray(pw *printWriter, kind string, n int, elem func(i int) string) {
+	p.colored(pw, colorContainer, fmt.Sprintf("[%d item %s]", n, kind))
+	if preview := p.arrayPreview(n, elem); preview != "" {
+		p.colored(pw, colorInteger, preview)
+	}
+}
+
+func (p Printer) print(pw *printWriter, t Tag, prefix interface{}, depth int) {
+	pw.printf("%s", strings.Repeat(p.Indent, depth))
+	switch v := prefix.(type) {
+	case nil:
+		// do nothing with a nil prefix
+	case int:
+		p.colored(pw, colorKey, fmt.Sprintf("[%d]", v))
+		pw.printf(": ")
+	default:
+		p.colored(pw, colorKey, fmt.Sprintf("%s", v))
+		pw.printf(": ")
+	}
+	defer pw.printf("\n")
+	elided := p.MaxDepth > 0 && depth >= p.MaxDepth
+	switch x := t.(type) {
+	default:
+		pw.printf("[unknown tag %v]", t.Type())
+	case End:
+		pw.printf("}")
+	case Byte, Short, Int, Long:
+		p.colored(pw, colorInteger, fmt.Sprintf("%d", x))
+	case Float:
+		p.colored(pw, colorFloat, p.formatFloat(float64(x), 32))
+	case Double:
+		p.colored(pw, colorFloat, p.formatFloat(float64(x), 64))
+	case String:
+		p.colored(pw, colorString, string(x))
+	case ByteArray:
+		p.printArray(pw, "byte", len(x), func(i int) string {
+			if p.Hex {
+				return fmt.Sprintf("%02x", uint8(x[i]))
+			}
+			return strconv.Itoa(int(x[i]))
+		})
+	case IntArray:
+		p.printArray(pw, "int", len(x), func(i int) string {
+			if p.Hex {
+				return fmt.Sprintf("%08x", uint32(x[i]))
+			}
+			return strconv.Itoa(int(x[i]))
+		})
+	case LongArray:
+		p.printArray(pw, "long", len(x), func(i int) string {
+			if p.Hex {
+				return fmt.Sprintf("%016x", uint64(x[i]))
+			}
+			return strconv.FormatInt(int64(x[i]), 10)
+		})
+	case List:
+		length := x.Length()
+		if x.Mixed() {
+			p.colored(pw, colorContainer, fmt.Sprintf("[%d mixed list]", length))
+		} else {
+			p.colored(pw, colorContainer, fmt.Sprintf("[%d %v list]", length, x.Contents))
+		}
+		if length != 0 && elided {
+			pw.printf(" {...}")
+			return
+		}
+		pw.printf(" {")
+		if length != 0 {
+			pw.printf("\n")
+			x.Iterate(func(i int, elt Tag) error {
+				p.print(pw, elt, i, depth+1)
+				return pw.err
+			})
+			pw.printf("%s", strings.Repeat(p.Indent, depth))
+		}
+		pw.printf("}")
+	case Compound:
+		p.colored(pw, colorContainer, fmt.Sprintf("compound [%d elements]", len(x)))
+		if len(x) != 0 && elided {
+			pw.printf(" {...}")
+			return
+		}
+		pw.printf(" {")
+		if len(x) != 0 {
+			pw.printf("\n")
+			var keys []String
+			if p.SortKeys {
+				keys = sortedKeys(x)
+			} else {
+				for k := range x {
+					keys = append(keys, k)
+				}
+			}
+			for _, k := range keys {
+				v, _ := x.entry(k)
+				p.print(pw, v, k, depth+1)
+			}
+			pw.printf("%s", strings.Repeat(p.Indent, depth))
+		}
+		pw.printf("}")
+	}
+}
@@ -0,0 +1,356 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/seebs/nbt"
+)
+
+// tokens are pretty simple; we keep the text of each one, and a kind.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokDot
+	tokDotDot
+	tokIdent
+	tokString
+	tokLiteral
+	tokOp
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// Error describes a failure to parse a query.
+type Error struct {
+	Query  string
+	Offset int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at offset %d in %q", e.Msg, e.Offset, e.Query)
+}
+
+type parser struct {
+	s    string
+	toks []token
+	next int
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &Error{Query: p.s, Offset: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// lex splits the query into tokens.
+func (p *parser) lex() error {
+	s := p.s
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '.':
+			if i+1 < len(s) && s[i+1] == '.' {
+				i += 2
+				p.toks = append(p.toks, token{tokDotDot, "..", start})
+			} else {
+				i++
+				p.toks = append(p.toks, token{tokDot, ".", start})
+			}
+		case c == '"':
+			i++
+			buf := &strings.Builder{}
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				buf.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return p.errorf(start, "unterminated string")
+			}
+			i++
+			p.toks = append(p.toks, token{tokString, buf.String(), start})
+		case c == '-' || (c >= '0' && c <= '9'):
+			i++
+			for i < len(s) && (isIdentChar(s[i]) || s[i] == '.') {
+				i++
+			}
+			p.toks = append(p.toks, token{tokLiteral, s[start:i], start})
+		case isIdentStart(c):
+			for i < len(s) && isIdentChar(s[i]) {
+				i++
+			}
+			p.toks = append(p.toks, token{tokIdent, s[start:i], start})
+		case strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="),
+			strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			i += 2
+			p.toks = append(p.toks, token{tokOp, s[start:i], start})
+		case c == '<' || c == '>':
+			i++
+			p.toks = append(p.toks, token{tokOp, s[start:i], start})
+		case strings.IndexByte("|,()[]", c) >= 0:
+			i++
+			p.toks = append(p.toks, token{tokPunct, s[start:i], start})
+		default:
+			return p.errorf(start, "unexpected %q", c)
+		}
+	}
+	p.toks = append(p.toks, token{tokEOF, "", len(s)})
+	return nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.toks[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+// is indicates whether the next token is the given punctuation or keyword.
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokPunct || t.kind == tokIdent || t.kind == tokOp) && t.text == text
+}
+
+func (p *parser) expect(text string) error {
+	if !p.is(text) {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return p.errorf(t.pos, "expected %q, found end of query", text)
+		}
+		return p.errorf(t.pos, "expected %q, found %q", text, t.text)
+	}
+	p.take()
+	return nil
+}
+
+// parsePipe handles `a | b`, the lowest-precedence operator.
+func (p *parser) parsePipe() (filter, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("|") {
+		p.take()
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = pipe{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseComma() (filter, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(",") {
+		p.take()
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = comma{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseOr() (filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("or") {
+		p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{left, right, true}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (filter, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("and") {
+		p.take()
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{left, right, false}
+	}
+	return left, nil
+}
+
+func (p *parser) parseCompare() (filter, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokOp {
+		return left, nil
+	}
+	op := p.take().text
+	right, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	return compare{left, right, op}, nil
+}
+
+// parsePostfix handles a primary expression followed by any number of
+// `.key` or `[...]` suffixes.
+func (p *parser) parsePostfix() (filter, error) {
+	f, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.peek().kind == tokDot:
+			next := p.toks[p.next+1]
+			if next.kind != tokIdent && next.kind != tokString {
+				return f, nil
+			}
+			p.take()
+			p.take()
+			f = pipe{f, key(next.text)}
+		case p.is("["):
+			idx, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			f = pipe{f, idx}
+		default:
+			return f, nil
+		}
+	}
+}
+
+// parseBracket handles `[]`, `[n]`, and `["key"]`.
+func (p *parser) parseBracket() (filter, error) {
+	p.take()
+	if p.is("]") {
+		p.take()
+		return iterate{}, nil
+	}
+	t := p.take()
+	var f filter
+	switch t.kind {
+	case tokString:
+		f = key(t.text)
+	case tokLiteral:
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, p.errorf(t.pos, "invalid index %q", t.text)
+		}
+		f = index(n)
+	default:
+		return nil, p.errorf(t.pos, "expected index or key, found %q", t.text)
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) parsePrimary() (filter, error) {
+	t := p.take()
+	switch t.kind {
+	case tokDotDot:
+		return recurse{}, nil
+	case tokDot:
+		next := p.peek()
+		switch {
+		case next.kind == tokIdent || next.kind == tokString:
+			p.take()
+			return key(next.text), nil
+		case p.is("["):
+			return p.parseBracket()
+		}
+		return identity{}, nil
+	case tokString:
+		return literal{nbt.String(t.text)}, nil
+	case tokLiteral:
+		v, err := nbt.ParseSNBT(t.text)
+		if err != nil {
+			return nil, p.errorf(t.pos, "invalid literal %q", t.text)
+		}
+		return literal{v}, nil
+	case tokPunct:
+		if t.text != "(" {
+			break
+		}
+		f, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal{nbt.Byte(1)}, nil
+		case "false":
+			return literal{nbt.Byte(0)}, nil
+		case "keys":
+			return keys{}, nil
+		case "length":
+			return length{}, nil
+		case "type":
+			return typeName{}, nil
+		case "not":
+			return not{}, nil
+		case "select":
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			cond, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+			return sel{cond}, nil
+		}
+		return nil, p.errorf(t.pos, "unknown function %q", t.text)
+	case tokEOF:
+		return nil, p.errorf(t.pos, "unexpected end of query")
+	}
+	return nil, p.errorf(t.pos, "unexpected %q", t.text)
+}
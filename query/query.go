@@ -0,0 +1,387 @@
+// Package query implements a small jq-like query language over NBT
+// tags, such as `.Data.Player.Inventory[] | select(.Count > 32) | .id`.
+//
+// A query takes a single input tag and produces a stream of zero or more
+// output tags. The supported filters are:
+//
+//	.              the input itself
+//	.key ."key"    the named entry of a compound (nothing if missing)
+//	.[n]           the nth element of a list or array; negative counts from the end
+//	.[]            every element of a list or array, or every value of a compound
+//	..             the input and everything inside it, recursively
+//	a | b          feed every output of a into b
+//	a, b           the outputs of a, then the outputs of b
+//	a == b         comparisons (==, !=, <, <=, >, >=), yielding 1b or 0b
+//	a and b        logical operators; also "or" and "not"
+//	select(f)      the input, if f yields any true value
+//	keys           sorted compound keys, or list/array indexes
+//	length         the length of a compound, list, array, or string, in characters
+//	type           the name of the input's Type
+//
+// Literals are written as SNBT, so `32`, `1b`, `"minecraft:stone"` and
+// `true` all work. Numbers of different types compare by value. A value is
+// false if it's numerically zero; everything else is true.
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/seebs/nbt"
+)
+
+// Query is a parsed query, ready to run against tags.
+type Query struct {
+	text string
+	f    filter
+}
+
+// Parse parses a query.
+func Parse(s string) (*Query, error) {
+	p := &parser{s: s}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	f, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t.pos, "unexpected %q", t.text)
+	}
+	return &Query{text: s, f: f}, nil
+}
+
+// String yields the text of the query.
+func (q *Query) String() string {
+	return q.text
+}
+
+// Each runs the query against t, calling fn on each result. If fn
+// returns an error, Each stops and returns that error.
+func (q *Query) Each(t nbt.Tag, fn func(nbt.Tag) error) error {
+	return q.f.eval(t, fn)
+}
+
+// Run runs the query against t, returning all the results.
+func (q *Query) Run(t nbt.Tag) ([]nbt.Tag, error) {
+	var out []nbt.Tag
+	err := q.Each(t, func(r nbt.Tag) error {
+		out = append(out, r)
+		return nil
+	})
+	return out, err
+}
+
+// A filter takes an input tag and emits zero or more outputs.
+type filter interface {
+	eval(in nbt.Tag, emit func(nbt.Tag) error) error
+}
+
+type identity struct{}
+
+func (identity) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	return emit(in)
+}
+
+type literal struct {
+	v nbt.Tag
+}
+
+func (l literal) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	return emit(l.v)
+}
+
+type key string
+
+func (k key) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	c, ok := in.(nbt.Compound)
+	if !ok {
+		return nil
+	}
+	if v, ok := nbt.TagElement(c, nbt.String(k)); ok {
+		return emit(v)
+	}
+	return nil
+}
+
+type index int
+
+func (i index) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	if in.Type() == nbt.TypeCompound {
+		return nil
+	}
+	idx := int(i)
+	if idx < 0 {
+		idx += nbt.TagLength(in)
+	}
+	if v, ok := nbt.TagElement(in, idx); ok {
+		return emit(v)
+	}
+	return nil
+}
+
+// sortedKeys yields the keys of c in order.
+func sortedKeys(c nbt.Compound) []nbt.String {
+	keys := make([]nbt.String, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// children emits every immediate child of in, in a stable order.
+func children(in nbt.Tag, emit func(nbt.Tag) error) error {
+	if c, ok := in.(nbt.Compound); ok {
+		for _, k := range sortedKeys(c) {
+			v, _ := nbt.TagElement(c, k)
+			if err := emit(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !nbt.TagHasElements(in) {
+		return nil
+	}
+	for i := 0; i < nbt.TagLength(in); i++ {
+		v, _ := nbt.TagElement(in, i)
+		if err := emit(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type iterate struct{}
+
+func (iterate) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	return children(in, emit)
+}
+
+type recurse struct{}
+
+func (r recurse) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	if err := emit(in); err != nil {
+		return err
+	}
+	return children(in, func(t nbt.Tag) error { return r.eval(t, emit) })
+}
+
+type pipe struct {
+	left, right filter
+}
+
+func (p pipe) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	return p.left.eval(in, func(t nbt.Tag) error {
+		return p.right.eval(t, emit)
+	})
+}
+
+type comma struct {
+	left, right filter
+}
+
+func (c comma) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	if err := c.left.eval(in, emit); err != nil {
+		return err
+	}
+	return c.right.eval(in, emit)
+}
+
+// boolTag converts a Go bool to the NBT convention of 1b/0b.
+func boolTag(b bool) nbt.Tag {
+	if b {
+		return nbt.Byte(1)
+	}
+	return nbt.Byte(0)
+}
+
+// truthy indicates whether t counts as true: anything but a numeric zero.
+func truthy(t nbt.Tag) bool {
+	if f, ok := numeric(t); ok {
+		return f != 0
+	}
+	return true
+}
+
+// integer yields the value of an integer tag as an int64.
+func integer(t nbt.Tag) (int64, bool) {
+	switch v := t.(type) {
+	case nbt.Byte:
+		return int64(v), true
+	case nbt.Short:
+		return int64(v), true
+	case nbt.Int:
+		return int64(v), true
+	case nbt.Long:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// numeric yields the value of a numeric tag as a float64.
+func numeric(t nbt.Tag) (float64, bool) {
+	switch v := t.(type) {
+	case nbt.Byte:
+		return float64(v), true
+	case nbt.Short:
+		return float64(v), true
+	case nbt.Int:
+		return float64(v), true
+	case nbt.Long:
+		return float64(v), true
+	case nbt.Float:
+		return float64(v), true
+	case nbt.Double:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+type compare struct {
+	left, right filter
+	op          string
+}
+
+// order compares a and b, yielding -1, 0, or 1, if they're comparable.
+// Integers are compared exactly, and only converted to float64 to compare
+// with a Float or Double.
+func order(a, b nbt.Tag) (int, bool) {
+	if x, ok := integer(a); ok {
+		if y, ok := integer(b); ok {
+			return cmp.Compare(x, y), true
+		}
+	}
+	if x, ok := numeric(a); ok {
+		y, ok := numeric(b)
+		switch {
+		case !ok:
+			return 0, false
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	if x, ok := a.(nbt.String); ok {
+		y, ok := b.(nbt.String)
+		switch {
+		case !ok:
+			return 0, false
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func (c compare) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	return c.left.eval(in, func(a nbt.Tag) error {
+		return c.right.eval(in, func(b nbt.Tag) error {
+			cmp, ok := order(a, b)
+			switch c.op {
+			case "==":
+				return emit(boolTag((ok && cmp == 0) || (!ok && nbt.TagEqual(a, b))))
+			case "!=":
+				return emit(boolTag(!((ok && cmp == 0) || (!ok && nbt.TagEqual(a, b)))))
+			}
+			if !ok {
+				return fmt.Errorf("can't compare %v with %v", a.Type(), b.Type())
+			}
+			switch c.op {
+			case "<":
+				return emit(boolTag(cmp < 0))
+			case "<=":
+				return emit(boolTag(cmp <= 0))
+			case ">":
+				return emit(boolTag(cmp > 0))
+			default:
+				return emit(boolTag(cmp >= 0))
+			}
+		})
+	})
+}
+
+type logical struct {
+	left, right filter
+	or          bool
+}
+
+func (l logical) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	return l.left.eval(in, func(a nbt.Tag) error {
+		if truthy(a) == l.or {
+			return emit(boolTag(l.or))
+		}
+		return l.right.eval(in, func(b nbt.Tag) error {
+			return emit(boolTag(truthy(b)))
+		})
+	})
+}
+
+type not struct{}
+
+func (not) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	return emit(boolTag(!truthy(in)))
+}
+
+type sel struct {
+	cond filter
+}
+
+// errSelected stops evaluating a select condition once it's true.
+var errSelected = fmt.Errorf("selected")
+
+func (s sel) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	err := s.cond.eval(in, func(t nbt.Tag) error {
+		if truthy(t) {
+			return errSelected
+		}
+		return nil
+	})
+	if err == errSelected {
+		return emit(in)
+	}
+	return err
+}
+
+type keys struct{}
+
+func (keys) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	if c, ok := in.(nbt.Compound); ok {
+		return emit(nbt.MakeStringList(sortedKeys(c)))
+	}
+	if !nbt.TagHasElements(in) {
+		return fmt.Errorf("%v has no keys", in.Type())
+	}
+	idx := make([]nbt.Int, nbt.TagLength(in))
+	for i := range idx {
+		idx[i] = nbt.Int(i)
+	}
+	return emit(nbt.MakeIntList(idx))
+}
+
+type length struct{}
+
+func (length) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	if s, ok := in.(nbt.String); ok {
+		return emit(nbt.Int(utf8.RuneCountInString(string(s))))
+	}
+	if !nbt.TagHasElements(in) {
+		return fmt.Errorf("%v has no length", in.Type())
+	}
+	return emit(nbt.Int(nbt.TagLength(in)))
+}
+
+type typeName struct{}
+
+func (typeName) eval(in nbt.Tag, emit func(nbt.Tag) error) error {
+	return emit(nbt.String(in.Type().String()))
+}
@@ -0,0 +1,69 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/seebs/nbt"
+)
+
+const sample = `{Data:{Player:{Inventory:[
+	{Slot:0b,id:"minecraft:stone",Count:64b},
+	{Slot:1b,id:"minecraft:dirt",Count:3b},
+	{Slot:2b,id:"minecraft:torch",Count:33b}
+],XpLevel:30,Pos:[I;1,2,3]},Big:9007199254740993L,Name:"héllo"}}`
+
+func TestQuery(t *testing.T) {
+	root, err := nbt.ParseSNBT(sample)
+	if err != nil {
+		t.Fatalf("parsing sample: %s", err)
+	}
+	cases := []struct {
+		q    string
+		want []string
+	}{
+		{".Data.Player.XpLevel", []string{"30"}},
+		{`.Data."Player".Pos[1]`, []string{"2"}},
+		{".Data.Player.Pos[-1]", []string{"3"}},
+		{".Data.Player.Missing", nil},
+		{".Data.Player.Inventory[] | select(.Count > 32) | .id", []string{`"minecraft:stone"`, `"minecraft:torch"`}},
+		{".Data.Player.Inventory[] | select(.Count > 32 and .Slot != 0) | .Slot", []string{"2b"}},
+		{".Data.Player.Inventory[] | select(.id == \"minecraft:dirt\" or .Count == 33) | .Slot", []string{"1b", "2b"}},
+		{".Data.Player | keys", []string{`["Inventory","Pos","XpLevel"]`}},
+		{".Data.Player.Inventory | length", []string{"3"}},
+		{".Data.Player.Inventory[0] | .Slot, .Count", []string{"0b", "64b"}},
+		{`.. | select(type == "Int") `, []string{"1", "2", "3", "30"}},
+		{".Data.Player.XpLevel | . >= 30, not", []string{"1b", "0b"}},
+		{".Data.Big | . == 9007199254740992L, . > 9007199254740992L, . == 9007199254740993L", []string{"0b", "1b", "1b"}},
+		{".Data.Big > 1.5", []string{"1b"}},
+		{".Data.Name | length", []string{"5"}},
+	}
+	for _, c := range cases {
+		q, err := Parse(c.q)
+		if err != nil {
+			t.Errorf("%q: parse error: %s", c.q, err)
+			continue
+		}
+		results, err := q.Run(root)
+		if err != nil {
+			t.Errorf("%q: run error: %s", c.q, err)
+			continue
+		}
+		if len(results) != len(c.want) {
+			t.Errorf("%q: expected %d results, got %d", c.q, len(c.want), len(results))
+			continue
+		}
+		for i, r := range results {
+			if got := nbt.FormatSNBT(r); got != c.want[i] {
+				t.Errorf("%q: result %d: expected %s, got %s", c.q, i, c.want[i], got)
+			}
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, s := range []string{"", ".a |", "select(.a", ".[", "frob", ".a ! 3"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%q: expected parse error", s)
+		}
+	}
+}
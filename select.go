@@ -0,0 +1,199 @@
+package nbt
+
+import (
+	"fmt"
+	"io"
+)
+
+// Selective decoding. With LoadOptions.Include, only the parts of the tree
+// selected by one of the given NBTPaths are decoded; everything else is
+// skipped using the length prefixes in the data, without being allocated.
+// The result is a sparse tree, in which compounds only have the entries
+// leading to selected nodes, and lists only the selected elements. That
+// means indexes into lists in the result needn't match the original.
+//
+// Selection works a step at a time, so steps which need a node's value
+// to decide whether it matches, such as `a{b:1b}` or `[{id:"x"}]`, select
+// every node that could match, including all of its contents.
+
+// selection holds the remaining steps of each path that's still being
+// followed. An empty entry means the whole node is selected.
+type selection [][]nbtPathNode
+
+// whole indicates whether the entire node is selected.
+func (sel selection) whole() bool {
+	for _, s := range sel {
+		if len(s) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// key yields the selection for the entry of a compound named key.
+func (sel selection) key(key String) (next selection) {
+	for _, s := range sel {
+		switch n := s[0].(type) {
+		case nbtPathKey:
+			if n.key == key {
+				next = append(next, s[1:])
+			}
+		case nbtPathMatchKey:
+			if n.key == key {
+				next = append(next, nil)
+			}
+		}
+	}
+	return next
+}
+
+// index yields the selection for element i of a list of length n.
+func (sel selection) index(i, length int) (next selection) {
+	for _, s := range sel {
+		switch n := s[0].(type) {
+		case nbtPathIndex:
+			if n.index == i || n.index+length == i {
+				next = append(next, s[1:])
+			}
+		case nbtPathAllElements:
+			next = append(next, s[1:])
+		case nbtPathMatchElements:
+			next = append(next, nil)
+		}
+	}
+	return next
+}
+
+// indexed indicates whether any path goes on to index a node.
+func (sel selection) indexed() bool {
+	for _, s := range sel {
+		switch s[0].(type) {
+		case nbtPathIndex, nbtPathAllElements:
+			return true
+		}
+	}
+	return false
+}
+
+// LoadPaths loads a tag from r, like Load, but only decodes the nodes
+// selected by paths. See LoadOptions.Include.
+func LoadPaths(r io.Reader, paths ...NBTPath) (Tag, String, error) {
+	return LoadOptions{Include: paths}.Load(r)
+}
+
+// rootSelection yields the initial selection for the paths.
+func rootSelection(paths []NBTPath) selection {
+	sel := make(selection, 0, len(paths))
+	for _, p := range paths {
+		if _, ok := p.nodes[0].(nbtPathRoot); ok {
+			// the root's filter needs the whole tree
+			sel = append(sel, nil)
+			continue
+		}
+		sel = append(sel, p.nodes)
+	}
+	return sel
+}
+
+// loadSelected loads the selected parts of a payload of type typ, and
+// indicates whether anything in it was selected.
+func loadSelected(d *decoder, typ Type, sel selection) (Tag, bool, error) {
+	if len(sel) == 0 {
+		return nil, false, skipPayload(d, typ)
+	}
+	if sel.whole() {
+		t, err := loadPayload(d, typ)
+		return t, err == nil, err
+	}
+	switch typ {
+	case TypeCompound:
+		c, err := selectCompound(d, sel, false)
+		return c, len(c) != 0, err
+	case TypeList:
+		l, err := selectList(d, sel)
+		return l, l.Length() != 0, err
+	case TypeByteArray, TypeIntArray, TypeLongArray:
+		// arrays are selected whole
+		if sel.indexed() {
+			t, err := loadPayload(d, typ)
+			return t, err == nil, err
+		}
+	}
+	return nil, false, skipPayload(d, typ)
+}
+
+// selectCompound loads the selected entries of a compound. In a list, a
+// compound may be a wrapper for a mixed list element, so the entry under
+// the empty key continues the list's selection.
+func selectCompound(d *decoder, sel selection, inList bool) (Compound, error) {
+	c := make(Compound)
+	for {
+		typ, name, err := loadHeader(d)
+		if err == io.EOF {
+			return c, fmt.Errorf("unterminated compound tag")
+		}
+		if err != nil {
+			return c, err
+		}
+		if typ == TypeEnd {
+			return c, nil
+		}
+		next := sel.key(name)
+		if inList && name == "" {
+			next = append(next, sel...)
+		}
+		t, ok, err := loadSelected(d, typ, next)
+		if err != nil {
+			return c, err
+		}
+		if ok {
+			c[name] = t
+		}
+	}
+}
+
+// selectList loads the selected elements of a list.
+func selectList(d *decoder, sel selection) (l List, err error) {
+	ttype, err := loadByte(d)
+	if err != nil {
+		return l, err
+	}
+	typ := Type(ttype)
+	if !validType(typ) {
+		return l, fmt.Errorf("invalid tag type for list: %d", ttype)
+	}
+	count, err := loadInt(d)
+	if err != nil {
+		return l, err
+	}
+	if count < 0 {
+		return l, fmt.Errorf("invalid negative count for list: %d", count)
+	}
+	var elems []Tag
+	for i := 0; i < int(count); i++ {
+		next := sel.index(i, int(count))
+		var t Tag
+		var ok bool
+		if typ == TypeCompound && len(next) != 0 && !next.whole() {
+			var c Compound
+			c, err = selectCompound(d, next, true)
+			t, ok = c, len(c) != 0
+		} else {
+			t, ok, err = loadSelected(d, typ, next)
+		}
+		if err != nil {
+			return l, err
+		}
+		if ok {
+			elems = append(elems, t)
+		}
+	}
+	if len(elems) == 0 {
+		return List{Contents: TypeEnd}, nil
+	}
+	l, err = makeListFromTags(typ, elems)
+	if err == nil && typ == TypeCompound && !d.opts.KeepWrappedLists {
+		l = unwrapList(l)
+	}
+	return l, err
+}
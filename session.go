@@ -0,0 +1,458 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Editing sessions, which record edits so they can be undone, redone, or
+// exported as a Patch and applied to another copy of the same data.
+
+// EditOp is the kind of change an Edit makes.
+type EditOp int
+
+const (
+	// EditSet replaces the tag at Path with Value, or adds it as a new
+	// Compound entry.
+	EditSet EditOp = iota
+	// EditDelete removes the tag at Path.
+	EditDelete
+	// EditRename changes the key of the Compound entry at Path to Key.
+	EditRename
+	// EditInsert inserts Value into a List or array, at the index which
+	// is the last component of Path.
+	EditInsert
+	// EditRemove removes an element from a List or array.
+	EditRemove
+)
+
+var editOpNames = []string{"set", "delete", "rename", "insert", "remove"}
+
+func (op EditOp) String() string {
+	if op < 0 || int(op) >= len(editOpNames) {
+		return fmt.Sprintf("EditOp(%d)", int(op))
+	}
+	return editOpNames[op]
+}
+
+// Edit is a single change to a tree. Path components are Strings for
+// Compound entries and Ints for List and array elements.
+type Edit struct {
+	Op    EditOp
+	Path  []PathComponent
+	Value Tag
+	Key   String
+}
+
+var (
+	SessionNoTransaction = errors.New("no transaction in progress")
+	SessionInTransaction = errors.New("transaction in progress")
+	SessionNothingToUndo = errors.New("nothing to undo")
+	SessionNothingToRedo = errors.New("nothing to redo")
+)
+
+// pathTo yields a Path to the tag reached by following comps from root.
+func pathTo(root Tag, comps []PathComponent) (Path, error) {
+	p := NewPath(root)
+	for _, c := range comps {
+		if _, err := p.Cd(c); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// applyEdit applies e to root, yielding the updated root, which may be a
+// new value, and an edit which undoes e. Values from e are copied, so the
+// tree never shares storage with an Edit.
+func applyEdit(root Tag, e Edit) (Tag, Edit, error) {
+	n := len(e.Path)
+	undo := Edit{Path: e.Path}
+	if n == 0 {
+		if e.Op != EditSet {
+			return root, undo, fmt.Errorf("can't %v root", e.Op)
+		}
+		undo.Op, undo.Value = EditSet, root
+		return TagCopy(e.Value), undo, nil
+	}
+	parent, err := pathTo(root, e.Path[:n-1])
+	if err != nil {
+		return root, undo, err
+	}
+	last := e.Path[n-1]
+	op := e.Op
+	if op == EditDelete && tagIsIndexable(parent.Current()) {
+		op = EditRemove
+	}
+	switch op {
+	case EditSet:
+		if c, ok := parent.Current().(Compound); ok {
+			if key, ok := last.(String); ok {
+				if _, exists := c[key]; !exists {
+					c[key] = TagCopy(e.Value)
+					undo.Op = EditDelete
+					return root, undo, nil
+				}
+			}
+		}
+		p, err := pathTo(root, e.Path)
+		if err != nil {
+			return root, undo, err
+		}
+		undo.Op, undo.Value = EditSet, p.Current()
+		err = p.Set(TagCopy(e.Value))
+		return p.Root(), undo, err
+	case EditDelete:
+		p, err := pathTo(root, e.Path)
+		if err != nil {
+			return root, undo, err
+		}
+		undo.Op, undo.Value = EditSet, p.Current()
+		err = p.Delete()
+		return p.Root(), undo, err
+	case EditRename:
+		p, err := pathTo(root, e.Path)
+		if err != nil {
+			return root, undo, err
+		}
+		old, ok := last.(String)
+		if !ok {
+			return root, undo, PathWrongType(parent.Current(), last)
+		}
+		if err = p.Rename(e.Key); err != nil {
+			return root, undo, err
+		}
+		undo.Op, undo.Key = EditRename, old
+		undo.Path = append(append([]PathComponent{}, e.Path[:n-1]...), e.Key)
+		return root, undo, nil
+	case EditInsert, EditRemove:
+		coll := parent.Current()
+		i, ok := componentIndex(last)
+		if !ok || !tagIsIndexable(coll) {
+			return root, undo, PathWrongType(coll, last)
+		}
+		elems := collectionElements(coll)
+		if op == EditInsert {
+			if i < 0 || i > len(elems) {
+				return root, undo, PathNoIndex(Int(i))
+			}
+			elems = append(elems[:i], append([]Tag{TagCopy(e.Value)}, elems[i:]...)...)
+			undo.Op = EditRemove
+		} else {
+			if i < 0 || i >= len(elems) {
+				return root, undo, PathNoIndex(Int(i))
+			}
+			undo.Op, undo.Value = EditInsert, elems[i]
+			elems = append(elems[:i], elems[i+1:]...)
+		}
+		coll, err = rebuildCollection(coll, elems)
+		if err != nil {
+			return root, undo, err
+		}
+		if err = parent.Set(coll); err != nil {
+			return root, undo, err
+		}
+		return parent.Root(), undo, nil
+	}
+	return root, undo, fmt.Errorf("unknown edit operation %v", e.Op)
+}
+
+// Transaction is a named group of edits, which are undone and redone
+// together.
+type Transaction struct {
+	Name  string
+	Edits []Edit
+	undo  []Edit
+}
+
+// Session edits a tree of tags, recording every change so it can be
+// undone. Changes made outside of a transaction are each their own
+// unnamed transaction.
+type Session struct {
+	root      Tag
+	done      []Transaction
+	undone    []Transaction
+	open      *Transaction
+	observers observers
+}
+
+// NewSession starts an editing session on root. Changes are made to root
+// in place where possible, but if root is a List or array, the edited
+// version is only available from Root. Lazily loaded values in root are
+// decoded, so edits to them aren't lost; any which fail to decode are left
+// as they are.
+func NewSession(root Tag) *Session {
+	root, _ = Decode(root)
+	return &Session{root: root}
+}
+
+// Root yields the current root of the tree being edited.
+func (s *Session) Root() Tag {
+	return s.root
+}
+
+// edit applies e to the tree, and notifies subscribers of the change,
+// yielding the edit which undoes it.
+func (s *Session) edit(e Edit) (Edit, error) {
+	root, undo, err := applyEdit(s.root, e)
+	if err != nil {
+		return undo, err
+	}
+	s.root = root
+	s.observers.notify(e, undo)
+	return undo, nil
+}
+
+// apply applies an edit and records it.
+func (s *Session) apply(e Edit) error {
+	if e.Value != nil {
+		v, err := Decode(TagCopy(e.Value))
+		if err != nil {
+			return err
+		}
+		e.Value = v
+	}
+	e.Path = append([]PathComponent{}, e.Path...)
+	undo, err := s.edit(e)
+	if err != nil {
+		return err
+	}
+	s.undone = nil
+	if s.open != nil {
+		s.open.Edits = append(s.open.Edits, e)
+		s.open.undo = append(s.open.undo, undo)
+		return nil
+	}
+	s.done = append(s.done, Transaction{Edits: []Edit{e}, undo: []Edit{undo}})
+	return nil
+}
+
+// Set replaces the tag at path with value, or adds a new Compound entry.
+func (s *Session) Set(value Tag, path ...PathComponent) error {
+	if value == nil {
+		return fmt.Errorf("can't set nil tag")
+	}
+	return s.apply(Edit{Op: EditSet, Path: path, Value: value})
+}
+
+// Delete removes the tag at path.
+func (s *Session) Delete(path ...PathComponent) error {
+	return s.apply(Edit{Op: EditDelete, Path: path})
+}
+
+// Rename changes the key of the Compound entry at path.
+func (s *Session) Rename(key String, path ...PathComponent) error {
+	return s.apply(Edit{Op: EditRename, Path: path, Key: key})
+}
+
+// Insert inserts value into a List or array, at the index which is the
+// last component of path.
+func (s *Session) Insert(value Tag, path ...PathComponent) error {
+	if value == nil {
+		return fmt.Errorf("can't insert nil tag")
+	}
+	return s.apply(Edit{Op: EditInsert, Path: path, Value: value})
+}
+
+// Remove removes an element from a List or array.
+func (s *Session) Remove(path ...PathComponent) error {
+	return s.apply(Edit{Op: EditRemove, Path: path})
+}
+
+// Begin starts a transaction. Transactions don't nest.
+func (s *Session) Begin(name string) error {
+	if s.open != nil {
+		return SessionInTransaction
+	}
+	s.open = &Transaction{Name: name}
+	return nil
+}
+
+// Commit ends the current transaction.
+func (s *Session) Commit() error {
+	if s.open == nil {
+		return SessionNoTransaction
+	}
+	if len(s.open.Edits) != 0 {
+		s.done = append(s.done, *s.open)
+	}
+	s.open = nil
+	return nil
+}
+
+// Rollback undoes the edits in the current transaction, and ends it.
+func (s *Session) Rollback() error {
+	if s.open == nil {
+		return SessionNoTransaction
+	}
+	err := s.revert(*s.open)
+	s.open = nil
+	return err
+}
+
+// revert applies the undo edits of t, in reverse order.
+func (s *Session) revert(t Transaction) error {
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		if _, err := s.edit(t.undo[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Undo undoes the most recent transaction.
+func (s *Session) Undo() error {
+	if s.open != nil {
+		return SessionInTransaction
+	}
+	if len(s.done) == 0 {
+		return SessionNothingToUndo
+	}
+	t := s.done[len(s.done)-1]
+	if err := s.revert(t); err != nil {
+		return err
+	}
+	s.done = s.done[:len(s.done)-1]
+	s.undone = append(s.undone, t)
+	return nil
+}
+
+// Redo redoes the most recently undone transaction. Making any other
+// change discards the transactions which could be redone.
+func (s *Session) Redo() error {
+	if s.open != nil {
+		return SessionInTransaction
+	}
+	if len(s.undone) == 0 {
+		return SessionNothingToRedo
+	}
+	t := s.undone[len(s.undone)-1]
+	for _, e := range t.Edits {
+		if _, err := s.edit(e); err != nil {
+			return err
+		}
+	}
+	s.undone = s.undone[:len(s.undone)-1]
+	s.done = append(s.done, t)
+	return nil
+}
+
+// History yields the transactions which have been made and not undone,
+// oldest first.
+func (s *Session) History() []Transaction {
+	return append([]Transaction{}, s.done...)
+}
+
+// Patch yields every edit which has been made and not undone, in order.
+func (s *Session) Patch() Patch {
+	var p Patch
+	for _, t := range s.done {
+		p = append(p, t.Edits...)
+	}
+	return p
+}
+
+// Patch is a sequence of edits, which can be applied to a tree, or
+// converted to a Tag to be stored.
+type Patch []Edit
+
+// Apply applies the edits in p to root, in order, yielding the updated
+// root. If an edit fails, the edits before it have already been made.
+func (p Patch) Apply(root Tag) (Tag, error) {
+	for i, e := range p {
+		var err error
+		root, _, err = applyEdit(root, e)
+		if err != nil {
+			return root, fmt.Errorf("edit %d (%v): %w", i, e.Op, err)
+		}
+	}
+	return root, nil
+}
+
+// Tag yields p as a List of Compounds, each with an "op", a "path" which
+// is a list of Strings and Ints, and a "value" or "key" if the operation
+// has one.
+func (p Patch) Tag() (List, error) {
+	out := make([]Compound, 0, len(p))
+	for _, e := range p {
+		comps := make([]Tag, len(e.Path))
+		for i, c := range e.Path {
+			t, ok := c.(Tag)
+			if !ok {
+				return List{}, fmt.Errorf("unsupported path component %T", c)
+			}
+			comps[i] = t
+		}
+		path, err := MakeMixedList(comps)
+		if err != nil {
+			return List{}, err
+		}
+		c := Compound{"op": String(e.Op.String()), "path": path}
+		if e.Value != nil {
+			c["value"] = TagCopy(e.Value)
+		}
+		if e.Op == EditRename {
+			c["key"] = e.Key
+		}
+		out = append(out, c)
+	}
+	return MakeCompoundList(out), nil
+}
+
+// PatchFromTag converts a tag made by Patch.Tag back into a Patch.
+func PatchFromTag(t Tag) (Patch, error) {
+	l, ok := t.(List)
+	if !ok || (l.Contents != TypeCompound && l.Length() != 0) {
+		return nil, fmt.Errorf("patch must be a list of compounds")
+	}
+	p := make(Patch, 0, l.Length())
+	for i, elt := range l.All() {
+		c, ok := elt.(Compound)
+		if !ok {
+			return nil, fmt.Errorf("edit %d: expected Compound, got %v", i, elt.Type())
+		}
+		var e Edit
+		op, err := c.Str("op")
+		if err != nil {
+			return nil, fmt.Errorf("edit %d: %w", i, err)
+		}
+		e.Op = -1
+		for j, name := range editOpNames {
+			if string(op) == name {
+				e.Op = EditOp(j)
+			}
+		}
+		if e.Op < 0 {
+			return nil, fmt.Errorf("edit %d: unknown operation %q", i, op)
+		}
+		path, err := c.List("path")
+		if err != nil {
+			return nil, fmt.Errorf("edit %d: %w", i, err)
+		}
+		for _, comp := range path.All() {
+			pc, ok := comp.(PathComponent)
+			if !ok {
+				return nil, fmt.Errorf("edit %d: invalid path component %v", i, comp.Type())
+			}
+			e.Path = append(e.Path, pc)
+		}
+		if v, ok := c["value"]; ok {
+			// the patch may have been loaded lazily
+			if e.Value, err = Decode(v); err != nil {
+				return nil, fmt.Errorf("edit %d: value: %w", i, err)
+			}
+		}
+		switch e.Op {
+		case EditSet, EditInsert:
+			if e.Value == nil {
+				return nil, fmt.Errorf("edit %d: %v needs a value", i, e.Op)
+			}
+		case EditRename:
+			if e.Key, err = c.Str("key"); err != nil {
+				return nil, fmt.Errorf("edit %d: %w", i, err)
+			}
+		}
+		p = append(p, e)
+	}
+	return p, nil
+}
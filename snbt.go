@@ -0,0 +1,489 @@
+package nbt
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SNBT is the "stringified" NBT format Minecraft uses in commands, such as
+// `{Count:1b,id:"minecraft:stone"}`. This file handles parsing it.
+
+// snbtParser holds the state of an in-progress parse, so that things like
+// NBT path parsing can parse a single value out of the middle of a larger
+// string.
+type snbtParser struct {
+	s   string
+	pos int
+}
+
+// SNBTError describes a failure to parse SNBT, including where in the
+// input the problem was found.
+type SNBTError struct {
+	Input  string
+	Offset int
+	Msg    string
+}
+
+func (e *SNBTError) Error() string {
+	return fmt.Sprintf("%s at offset %d in %q", e.Msg, e.Offset, e.Input)
+}
+
+func (p *snbtParser) errorf(format string, args ...interface{}) error {
+	return &SNBTError{Input: p.s, Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ParseSNBT parses a single SNBT value, which must be the entire string
+// (other than surrounding whitespace).
+func ParseSNBT(s string) (Tag, error) {
+	p := &snbtParser{s: s}
+	t, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.done() {
+		return nil, p.errorf("trailing data after value")
+	}
+	return t, nil
+}
+
+func (p *snbtParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *snbtParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *snbtParser) skipSpace() {
+	for !p.done() {
+		switch p.s[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// expect skips whitespace, then consumes the byte c or fails.
+func (p *snbtParser) expect(c byte) error {
+	p.skipSpace()
+	if p.peek() != c {
+		if p.done() {
+			return p.errorf("expected %q, found end of input", c)
+		}
+		return p.errorf("expected %q, found %q", c, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// isUnquotedChar indicates whether c may appear in an unquoted string.
+func isUnquotedChar(c byte) bool {
+	return (c >= '0' && c <= '9') ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		c == '_' || c == '-' || c == '.' || c == '+'
+}
+
+func (p *snbtParser) readUnquoted() string {
+	start := p.pos
+	for !p.done() && isUnquotedChar(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// readQuoted reads a string delimited by the quote character at the
+// current position. Backslash escapes only the quote character and
+// backslash itself.
+func (p *snbtParser) readQuoted() (string, error) {
+	quote := p.peek()
+	start := p.pos
+	p.pos++
+	buf := &strings.Builder{}
+	for !p.done() {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '\\':
+			if p.done() {
+				break
+			}
+			next := p.s[p.pos]
+			if next != quote && next != '\\' {
+				p.pos--
+				return "", p.errorf("invalid escape sequence \\%c", next)
+			}
+			buf.WriteByte(next)
+			p.pos++
+		case quote:
+			return buf.String(), nil
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated quoted string")
+}
+
+// readString reads either a quoted or unquoted string, as used for
+// compound keys.
+func (p *snbtParser) readString() (string, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case '"', '\'':
+		return p.readQuoted()
+	}
+	s := p.readUnquoted()
+	if s == "" {
+		if p.done() {
+			return "", p.errorf("expected string, found end of input")
+		}
+		return "", p.errorf("expected string, found %q", p.peek())
+	}
+	return s, nil
+}
+
+func (p *snbtParser) parseValue() (Tag, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case '{':
+		return p.parseCompound()
+	case '[':
+		return p.parseListOrArray()
+	case '"', '\'':
+		s, err := p.readQuoted()
+		return String(s), err
+	}
+	start := p.pos
+	s := p.readUnquoted()
+	if s == "" {
+		if p.done() {
+			return nil, p.errorf("expected value, found end of input")
+		}
+		return nil, p.errorf("expected value, found %q", p.peek())
+	}
+	t, err := parseScalar(s)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("%s", err)
+	}
+	return t, nil
+}
+
+// parseCompound parses a compound, starting at its opening brace.
+func (p *snbtParser) parseCompound() (Compound, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	c := make(Compound)
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		return c, nil
+	}
+	for {
+		key, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(':'); err != nil {
+			return nil, err
+		}
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		c[String(key)] = val
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return c, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in compound")
+		}
+	}
+}
+
+// parseListOrArray handles both lists, `[1,2,3]`, and typed arrays,
+// `[I;1,2,3]`.
+func (p *snbtParser) parseListOrArray() (Tag, error) {
+	if err := p.expect('['); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos+1 < len(p.s) && p.s[p.pos+1] == ';' {
+		switch p.s[p.pos] {
+		case 'B', 'I', 'L':
+			return p.parseArray()
+		}
+		return nil, p.errorf("invalid array type %q", p.s[p.pos])
+	}
+	var items []Tag
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+		return List{Contents: TypeEnd}, nil
+	}
+	for {
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, val)
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return MakeMixedList(items)
+		default:
+			return nil, p.errorf("expected ',' or ']' in list")
+		}
+	}
+}
+
+// parseArray parses the contents of a typed array, starting at the
+// type letter.
+func (p *snbtParser) parseArray() (Tag, error) {
+	kind := p.s[p.pos]
+	p.pos += 2
+	var want Type
+	switch kind {
+	case 'B':
+		want = TypeByte
+	case 'I':
+		want = TypeInt
+	case 'L':
+		want = TypeLong
+	}
+	var ba ByteArray
+	var ia IntArray
+	var la LongArray
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+	} else {
+	elements:
+		for {
+			start := p.pos
+			val, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if val.Type() != want {
+				p.pos = start
+				return nil, p.errorf("can't insert %v into %v array", val.Type(), want)
+			}
+			switch v := val.(type) {
+			case Byte:
+				ba = append(ba, int8(v))
+			case Int:
+				ia = append(ia, v)
+			case Long:
+				la = append(la, v)
+			}
+			p.skipSpace()
+			switch p.peek() {
+			case ',':
+				p.pos++
+			case ']':
+				p.pos++
+				break elements
+			default:
+				return nil, p.errorf("expected ',' or ']' in array")
+			}
+		}
+	}
+	switch want {
+	case TypeByte:
+		if ba == nil {
+			ba = ByteArray{}
+		}
+		return ba, nil
+	case TypeInt:
+		if ia == nil {
+			ia = IntArray{}
+		}
+		return ia, nil
+	default:
+		if la == nil {
+			la = LongArray{}
+		}
+		return la, nil
+	}
+}
+
+var (
+	snbtDoubleNoSuffix = regexp.MustCompile(`^[-+]?(?:[0-9]+[.]|[0-9]*[.][0-9]+)(?:[eE][-+]?[0-9]+)?$`)
+	snbtDouble         = regexp.MustCompile(`^[-+]?(?:[0-9]+[.]?|[0-9]*[.][0-9]+)(?:[eE][-+]?[0-9]+)?[dD]$`)
+	snbtFloat          = regexp.MustCompile(`^[-+]?(?:[0-9]+[.]?|[0-9]*[.][0-9]+)(?:[eE][-+]?[0-9]+)?[fF]$`)
+	snbtByte           = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)[bB]$`)
+	snbtShort          = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)[sS]$`)
+	snbtLong           = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)[lL]$`)
+	snbtInt            = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)$`)
+)
+
+// parseScalar interprets an unquoted token as a number, a boolean, or
+// failing those, a plain string. Numbers which match a numeric pattern
+// but are out of range are errors, rather than silently becoming strings.
+func parseScalar(s string) (Tag, error) {
+	switch {
+	case snbtDoubleNoSuffix.MatchString(s):
+		f, err := strconv.ParseFloat(s, 64)
+		return Double(f), err
+	case snbtDouble.MatchString(s):
+		f, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		return Double(f), err
+	case snbtFloat.MatchString(s):
+		f, err := strconv.ParseFloat(s[:len(s)-1], 32)
+		return Float(f), err
+	case snbtByte.MatchString(s):
+		i, err := strconv.ParseInt(s[:len(s)-1], 10, 8)
+		return Byte(i), err
+	case snbtShort.MatchString(s):
+		i, err := strconv.ParseInt(s[:len(s)-1], 10, 16)
+		return Short(i), err
+	case snbtLong.MatchString(s):
+		i, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		return Long(i), err
+	case snbtInt.MatchString(s):
+		i, err := strconv.ParseInt(s, 10, 32)
+		return Int(i), err
+	case s == "true":
+		return Byte(1), nil
+	case s == "false":
+		return Byte(0), nil
+	}
+	return String(s), nil
+}
+
+// FormatSNBT formats t as SNBT, which ParseSNBT can read back. Compound
+// keys are sorted, so the output is stable.
+func FormatSNBT(t Tag) string {
+	buf := &strings.Builder{}
+	writeSNBT(buf, t)
+	return buf.String()
+}
+
+// quoteSNBT quotes s for SNBT, preferring double quotes unless s contains
+// double quotes but not single quotes.
+func quoteSNBT(s string) string {
+	quote := byte('"')
+	if strings.IndexByte(s, '"') >= 0 && strings.IndexByte(s, '\'') < 0 {
+		quote = '\''
+	}
+	buf := make([]byte, 0, len(s)+2)
+	buf = append(buf, quote)
+	for i := 0; i < len(s); i++ {
+		if s[i] == quote || s[i] == '\\' {
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, s[i])
+	}
+	buf = append(buf, quote)
+	return string(buf)
+}
+
+// snbtKey formats a compound key, quoting it only if necessary.
+func snbtKey(s String) string {
+	if s == "" {
+		return `""`
+	}
+	for i := 0; i < len(s); i++ {
+		if !isUnquotedChar(s[i]) {
+			return quoteSNBT(string(s))
+		}
+	}
+	return string(s)
+}
+
+func writeSNBT(buf *strings.Builder, t Tag) {
+	switch x := t.(type) {
+	case Byte:
+		fmt.Fprintf(buf, "%db", x)
+	case Short:
+		fmt.Fprintf(buf, "%ds", x)
+	case Int:
+		fmt.Fprintf(buf, "%d", x)
+	case Long:
+		fmt.Fprintf(buf, "%dL", x)
+	case Float:
+		buf.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+		buf.WriteByte('f')
+	case Double:
+		buf.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 64))
+		buf.WriteByte('d')
+	case String:
+		buf.WriteString(quoteSNBT(string(x)))
+	case ByteArray:
+		buf.WriteString("[B;")
+		for i, v := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, "%db", v)
+		}
+		buf.WriteByte(']')
+	case IntArray:
+		buf.WriteString("[I;")
+		for i, v := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, "%d", v)
+		}
+		buf.WriteByte(']')
+	case LongArray:
+		buf.WriteString("[L;")
+		for i, v := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, "%dL", v)
+		}
+		buf.WriteByte(']')
+	case List:
+		buf.WriteByte('[')
+		x.Iterate(func(i int, t Tag) error {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeSNBT(buf, t)
+			return nil
+		})
+		buf.WriteByte(']')
+	case Compound:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(snbtKey(String(k)))
+			buf.WriteByte(':')
+			v, _ := x.entry(String(k))
+			writeSNBT(buf, v)
+		}
+		buf.WriteByte('}')
+	case End:
+	default:
+		fmt.Fprintf(buf, "<unknown tag %v>", t.Type())
+	}
+}
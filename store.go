@@ -10,41 +10,96 @@ import (
 
 // functionality related to storing Tags to streams
 
-// Store stores `n` to the provided io.Writer. It does
-// not handle compression; for that, use the non-method Store.
+// StoreTag stores t, with the given name, to the provided io.Writer, in a
+// single call to Write. It does not handle compression; for that, use
+// Store.
+//
+// The whole encoding is built in memory before anything is written, so
+// storing a tree needs a buffer of its full encoded size; EncodedSize
+// says how big. Buffers up to 1 MiB are pooled for reuse, and larger ones
+// are left for the garbage collector.
 func StoreTag(w io.Writer, t Tag, name String) error {
-	// TypeEnd doesn't get its name written.
-	if t.Type() == TypeEnd {
-		_, err := w.Write([]byte{0})
-		return err
-	}
-	l := len(name)
-	var b [3]byte
-	b[0] = byte(t.Type())
-	b[1] = byte((l >> 8) & 0xFF)
-	b[2] = byte(l & 0xFF)
-	_, err := w.Write(b[0:3])
-	if err != nil {
-		return err
-	}
-	_, err = w.Write([]byte(name))
-	if err != nil {
-		return err
+	return storeWith(w, func(dst []byte) ([]byte, error) {
+		return appendTag(dst, t, name)
+	})
+}
+
+// EncodedSize yields the number of bytes t.Store would write, computing it
+// without writing anything. StoreTag also writes a header, which is 3
+// bytes plus the length of the name, except for End.
+func EncodedSize(t Tag) int {
+	switch x := t.(type) {
+	case End:
+		return 0
+	case Byte:
+		return 1
+	case Short:
+		return 2
+	case Int, Float:
+		return 4
+	case Long, Double:
+		return 8
+	case String:
+		return 2 + len(x)
+	case ByteArray:
+		return 4 + len(x)
+	case IntArray:
+		return 4 + 4*len(x)
+	case LongArray:
+		return 4 + 8*len(x)
+	case List:
+		size := 5
+		mixed := x.Mixed()
+		if !mixed && x.Contents < TypeMax && payloadSizes[x.Contents] != 0 {
+			return size + x.Length()*int(payloadSizes[x.Contents])
+		}
+		x.Iterate(func(_ int, elt Tag) error {
+			if mixed {
+				elt = wrapElement(elt)
+			}
+			size += EncodedSize(elt)
+			return nil
+		})
+		return size
+	case Compound:
+		size := 1
+		for k, v := range x {
+			size += 3 + len(k) + EncodedSize(v)
+		}
+		return size
+	case *lazyTag:
+		if v := x.decoded(); v != nil {
+			return EncodedSize(v)
+		}
+		return len(x.raw)
 	}
-	return t.store(w)
+	// custom types can only be measured by storing them
+	cw := &countingWriter{}
+	t.Store(cw)
+	return int(cw.n)
+}
+
+// countingWriter discards what's written to it, counting the bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
 }
 
-func (p End) store(w io.Writer) error {
+func (p End) Store(w io.Writer) error {
 	return nil
 }
 
-func (p Byte) store(w io.Writer) error {
+func (p Byte) Store(w io.Writer) error {
 	b := [1]byte{byte(p)}
 	_, err := w.Write(b[0:1])
 	return err
 }
 
-func (p Short) store(w io.Writer) error {
+func (p Short) Store(w io.Writer) error {
 	var b [2]byte
 	b[0] = byte((p >> 8) & 0xFF)
 	b[1] = byte(p & 0xFF)
@@ -52,7 +107,7 @@ func (p Short) store(w io.Writer) error {
 	return err
 }
 
-func (p Int) store(w io.Writer) error {
+func (p Int) Store(w io.Writer) error {
 	var b [4]byte
 	b[0] = byte((p >> 24) & 0xFF)
 	b[1] = byte((p >> 16) & 0xFF)
@@ -62,7 +117,7 @@ func (p Int) store(w io.Writer) error {
 	return err
 }
 
-func (p Long) store(w io.Writer) error {
+func (p Long) Store(w io.Writer) error {
 	var b [8]byte
 	b[0] = byte((p >> 56) & 0xFF)
 	b[1] = byte((p >> 48) & 0xFF)
@@ -76,7 +131,7 @@ func (p Long) store(w io.Writer) error {
 	return err
 }
 
-func (p Float) store(w io.Writer) error {
+func (p Float) Store(w io.Writer) error {
 	var b [4]byte
 	f := math.Float32bits(float32(p))
 	b[0] = byte((f >> 24) & 0xFF)
@@ -87,7 +142,7 @@ func (p Float) store(w io.Writer) error {
 	return err
 }
 
-func (p Double) store(w io.Writer) error {
+func (p Double) Store(w io.Writer) error {
 	var b [8]byte
 	f := math.Float64bits(float64(p))
 	b[0] = byte((f >> 56) & 0xFF)
@@ -102,9 +157,9 @@ func (p Double) store(w io.Writer) error {
 	return err
 }
 
-func (p ByteArray) store(w io.Writer) error {
+func (p ByteArray) Store(w io.Writer) error {
 	l := Int(len(p))
-	err := l.store(w)
+	err := l.Store(w)
 	if err != nil {
 		return err
 	}
@@ -112,12 +167,12 @@ func (p ByteArray) store(w io.Writer) error {
 	return err
 }
 
-func (p String) store(w io.Writer) error {
+func (p String) Store(w io.Writer) error {
 	if len(p) > 32767 {
 		return fmt.Errorf("can't store %d-byte string", len(p))
 	}
 	sh := Short(len(p))
-	err := sh.store(w)
+	err := sh.Store(w)
 	if err != nil {
 		return err
 	}
@@ -125,57 +180,20 @@ func (p String) store(w io.Writer) error {
 	return err
 }
 
-func (p List) store(w io.Writer) error {
-	err := Byte(p.Contents).store(w)
-	if err != nil {
-		return err
-	}
-	l := Int(p.Length())
-	err = l.store(w)
-	if err != nil {
-		return err
-	}
-	return p.storeData(w)
+func (p List) Store(w io.Writer) error {
+	return storePayload(w, p)
 }
 
-func (p Compound) store(w io.Writer) error {
-	for k, v := range p {
-		err := StoreTag(w, v, k)
-		if err != nil {
-			return err
-		}
-	}
-	return StoreTag(w, End{}, "")
+func (p Compound) Store(w io.Writer) error {
+	return storePayload(w, p)
 }
 
-func (p IntArray) store(w io.Writer) error {
-	l := Int(len(p))
-	err := l.store(w)
-	if err != nil {
-		return err
-	}
-	for _, i := range p {
-		err = i.store(w)
-		if err != nil {
-			return err
-		}
-	}
-	return err
+func (p IntArray) Store(w io.Writer) error {
+	return storePayload(w, p)
 }
 
-func (p LongArray) store(w io.Writer) error {
-	l := Int(len(p))
-	err := l.store(w)
-	if err != nil {
-		return err
-	}
-	for _, i := range p {
-		err = i.store(w)
-		if err != nil {
-			return err
-		}
-	}
-	return err
+func (p LongArray) Store(w io.Writer) error {
+	return storePayload(w, p)
 }
 
 // StoreCompressed writes t to w, compressed via gzip.
@@ -4,13 +4,15 @@ package nbt
 
 import (
 	"fmt"
-	"io"
 )
 
 // End represents the NBT type TAG_End
 // Type() tells you that End represents TypeEnd.
 func (End) Type() Type { return TypeEnd }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x End) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 func GetEnd(t Tag) (out End, ok bool) {
 	if t.Type() != TypeEnd {
 		return out, false
@@ -22,6 +24,9 @@ func GetEnd(t Tag) (out End, ok bool) {
 // Type() tells you that Byte represents TypeByte.
 func (Byte) Type() Type { return TypeByte }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x Byte) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetByte performs a type-assertion that n is of type TypeByte. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to Byte, otherwise you get a zero-valued Byte and ok is
@@ -40,15 +45,19 @@ func (l List) GetByteList() (out []Byte, ok bool) {
 	if l.Contents != TypeByte {
 		return out, false
 	}
-	out, ok = l.data.([]Byte)
-	return out, ok
+	data, ok := l.data.(TypedList[Byte])
+	return []Byte(data), ok
 }
 
 // MakeByteList creates a list of the appropriate type of payload.
 func MakeByteList(in []Byte) (l List) {
-	l.Contents = TypeByte
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// Byte yields the Byte stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) Byte(key String) (Byte, error) {
+	return Get[Byte](c, key)
 }
 
 
@@ -56,6 +65,9 @@ func MakeByteList(in []Byte) (l List) {
 // Type() tells you that Short represents TypeShort.
 func (Short) Type() Type { return TypeShort }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x Short) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetShort performs a type-assertion that n is of type TypeShort. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to Short, otherwise you get a zero-valued Short and ok is
@@ -74,15 +86,19 @@ func (l List) GetShortList() (out []Short, ok bool) {
 	if l.Contents != TypeShort {
 		return out, false
 	}
-	out, ok = l.data.([]Short)
-	return out, ok
+	data, ok := l.data.(TypedList[Short])
+	return []Short(data), ok
 }
 
 // MakeShortList creates a list of the appropriate type of payload.
 func MakeShortList(in []Short) (l List) {
-	l.Contents = TypeShort
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// Short yields the Short stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) Short(key String) (Short, error) {
+	return Get[Short](c, key)
 }
 
 
@@ -90,6 +106,9 @@ func MakeShortList(in []Short) (l List) {
 // Type() tells you that Int represents TypeInt.
 func (Int) Type() Type { return TypeInt }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x Int) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetInt performs a type-assertion that n is of type TypeInt. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to Int, otherwise you get a zero-valued Int and ok is
@@ -108,15 +127,19 @@ func (l List) GetIntList() (out []Int, ok bool) {
 	if l.Contents != TypeInt {
 		return out, false
 	}
-	out, ok = l.data.([]Int)
-	return out, ok
+	data, ok := l.data.(TypedList[Int])
+	return []Int(data), ok
 }
 
 // MakeIntList creates a list of the appropriate type of payload.
 func MakeIntList(in []Int) (l List) {
-	l.Contents = TypeInt
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// Int yields the Int stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) Int(key String) (Int, error) {
+	return Get[Int](c, key)
 }
 
 
@@ -124,6 +147,9 @@ func MakeIntList(in []Int) (l List) {
 // Type() tells you that Long represents TypeLong.
 func (Long) Type() Type { return TypeLong }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x Long) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetLong performs a type-assertion that n is of type TypeLong. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to Long, otherwise you get a zero-valued Long and ok is
@@ -142,15 +168,19 @@ func (l List) GetLongList() (out []Long, ok bool) {
 	if l.Contents != TypeLong {
 		return out, false
 	}
-	out, ok = l.data.([]Long)
-	return out, ok
+	data, ok := l.data.(TypedList[Long])
+	return []Long(data), ok
 }
 
 // MakeLongList creates a list of the appropriate type of payload.
 func MakeLongList(in []Long) (l List) {
-	l.Contents = TypeLong
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// Long yields the Long stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) Long(key String) (Long, error) {
+	return Get[Long](c, key)
 }
 
 
@@ -158,6 +188,9 @@ func MakeLongList(in []Long) (l List) {
 // Type() tells you that Float represents TypeFloat.
 func (Float) Type() Type { return TypeFloat }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x Float) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetFloat performs a type-assertion that n is of type TypeFloat. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to Float, otherwise you get a zero-valued Float and ok is
@@ -176,15 +209,19 @@ func (l List) GetFloatList() (out []Float, ok bool) {
 	if l.Contents != TypeFloat {
 		return out, false
 	}
-	out, ok = l.data.([]Float)
-	return out, ok
+	data, ok := l.data.(TypedList[Float])
+	return []Float(data), ok
 }
 
 // MakeFloatList creates a list of the appropriate type of payload.
 func MakeFloatList(in []Float) (l List) {
-	l.Contents = TypeFloat
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// Float yields the Float stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) Float(key String) (Float, error) {
+	return Get[Float](c, key)
 }
 
 
@@ -192,6 +229,9 @@ func MakeFloatList(in []Float) (l List) {
 // Type() tells you that Double represents TypeDouble.
 func (Double) Type() Type { return TypeDouble }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x Double) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetDouble performs a type-assertion that n is of type TypeDouble. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to Double, otherwise you get a zero-valued Double and ok is
@@ -210,15 +250,19 @@ func (l List) GetDoubleList() (out []Double, ok bool) {
 	if l.Contents != TypeDouble {
 		return out, false
 	}
-	out, ok = l.data.([]Double)
-	return out, ok
+	data, ok := l.data.(TypedList[Double])
+	return []Double(data), ok
 }
 
 // MakeDoubleList creates a list of the appropriate type of payload.
 func MakeDoubleList(in []Double) (l List) {
-	l.Contents = TypeDouble
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// Double yields the Double stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) Double(key String) (Double, error) {
+	return Get[Double](c, key)
 }
 
 
@@ -226,6 +270,9 @@ func MakeDoubleList(in []Double) (l List) {
 // Type() tells you that ByteArray represents TypeByteArray.
 func (ByteArray) Type() Type { return TypeByteArray }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x ByteArray) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetByteArray performs a type-assertion that n is of type TypeByteArray. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to ByteArray, otherwise you get a zero-valued ByteArray and ok is
@@ -244,15 +291,19 @@ func (l List) GetByteArrayList() (out []ByteArray, ok bool) {
 	if l.Contents != TypeByteArray {
 		return out, false
 	}
-	out, ok = l.data.([]ByteArray)
-	return out, ok
+	data, ok := l.data.(TypedList[ByteArray])
+	return []ByteArray(data), ok
 }
 
 // MakeByteArrayList creates a list of the appropriate type of payload.
 func MakeByteArrayList(in []ByteArray) (l List) {
-	l.Contents = TypeByteArray
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// ByteArray yields the ByteArray stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) ByteArray(key String) (ByteArray, error) {
+	return Get[ByteArray](c, key)
 }
 
 
@@ -260,6 +311,9 @@ func MakeByteArrayList(in []ByteArray) (l List) {
 // Type() tells you that String represents TypeString.
 func (String) Type() Type { return TypeString }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x String) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetString performs a type-assertion that n is of type TypeString. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to String, otherwise you get a zero-valued String and ok is
@@ -278,22 +332,24 @@ func (l List) GetStringList() (out []String, ok bool) {
 	if l.Contents != TypeString {
 		return out, false
 	}
-	out, ok = l.data.([]String)
-	return out, ok
+	data, ok := l.data.(TypedList[String])
+	return []String(data), ok
 }
 
 // MakeStringList creates a list of the appropriate type of payload.
 func MakeStringList(in []String) (l List) {
-	l.Contents = TypeString
-	l.data = in
-	return l
+	return NewList(in)
 }
 
 
+
 // List represents the NBT type TAG_List
 // Type() tells you that List represents TypeList.
 func (List) Type() Type { return TypeList }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x List) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetList performs a type-assertion that n is of type TypeList. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to List, otherwise you get a zero-valued List and ok is
@@ -312,15 +368,19 @@ func (l List) GetListList() (out []List, ok bool) {
 	if l.Contents != TypeList {
 		return out, false
 	}
-	out, ok = l.data.([]List)
-	return out, ok
+	data, ok := l.data.(TypedList[List])
+	return []List(data), ok
 }
 
 // MakeListList creates a list of the appropriate type of payload.
 func MakeListList(in []List) (l List) {
-	l.Contents = TypeList
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// List yields the List stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) List(key String) (List, error) {
+	return Get[List](c, key)
 }
 
 
@@ -328,6 +388,9 @@ func MakeListList(in []List) (l List) {
 // Type() tells you that Compound represents TypeCompound.
 func (Compound) Type() Type { return TypeCompound }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x Compound) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetCompound performs a type-assertion that n is of type TypeCompound. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to Compound, otherwise you get a zero-valued Compound and ok is
@@ -346,15 +409,19 @@ func (l List) GetCompoundList() (out []Compound, ok bool) {
 	if l.Contents != TypeCompound {
 		return out, false
 	}
-	out, ok = l.data.([]Compound)
-	return out, ok
+	data, ok := l.data.(TypedList[Compound])
+	return []Compound(data), ok
 }
 
 // MakeCompoundList creates a list of the appropriate type of payload.
 func MakeCompoundList(in []Compound) (l List) {
-	l.Contents = TypeCompound
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// Compound yields the Compound stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) Compound(key String) (Compound, error) {
+	return Get[Compound](c, key)
 }
 
 
@@ -362,6 +429,9 @@ func MakeCompoundList(in []Compound) (l List) {
 // Type() tells you that IntArray represents TypeIntArray.
 func (IntArray) Type() Type { return TypeIntArray }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x IntArray) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetIntArray performs a type-assertion that n is of type TypeIntArray. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to IntArray, otherwise you get a zero-valued IntArray and ok is
@@ -380,15 +450,19 @@ func (l List) GetIntArrayList() (out []IntArray, ok bool) {
 	if l.Contents != TypeIntArray {
 		return out, false
 	}
-	out, ok = l.data.([]IntArray)
-	return out, ok
+	data, ok := l.data.(TypedList[IntArray])
+	return []IntArray(data), ok
 }
 
 // MakeIntArrayList creates a list of the appropriate type of payload.
 func MakeIntArrayList(in []IntArray) (l List) {
-	l.Contents = TypeIntArray
-	l.data = in
-	return l
+	return NewList(in)
+}
+
+// IntArray yields the IntArray stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) IntArray(key String) (IntArray, error) {
+	return Get[IntArray](c, key)
 }
 
 
@@ -396,6 +470,9 @@ func MakeIntArrayList(in []IntArray) (l List) {
 // Type() tells you that LongArray represents TypeLongArray.
 func (LongArray) Type() Type { return TypeLongArray }
 
+// Format implements fmt.Formatter; see formatTag.
+func (x LongArray) Format(f fmt.State, verb rune) { formatTag(f, verb, x) }
+
 // GetLongArray performs a type-assertion that n is of type TypeLongArray. If
 // it is, and there is a payload, you get the results of a type-assertion
 // of payload to LongArray, otherwise you get a zero-valued LongArray and ok is
@@ -414,620 +491,58 @@ func (l List) GetLongArrayList() (out []LongArray, ok bool) {
 	if l.Contents != TypeLongArray {
 		return out, false
 	}
-	out, ok = l.data.([]LongArray)
-	return out, ok
+	data, ok := l.data.(TypedList[LongArray])
+	return []LongArray(data), ok
 }
 
 // MakeLongArrayList creates a list of the appropriate type of payload.
 func MakeLongArrayList(in []LongArray) (l List) {
-	l.Contents = TypeLongArray
-	l.data = in
-	return l
-}
-
-
-
-
-func (l List) storeData(w io.Writer) (err error) {
-	switch raw := l.data.(type) {
-
-	case []End: // no data to store
-		return nil
-
-	case []Byte:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []Short:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []Int:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []Long:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []Float:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []Double:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []ByteArray:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []String:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []List:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []Compound:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []IntArray:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	case []LongArray:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-			err = raw[i].store(w)
-			if err != nil {
-				return err
-			}
-		}
-
-	default:
-		return fmt.Errorf("unhandled tag type in List.storeData: %v", l.Contents)
-	}
-	return nil
-}
-
-// loadData loads the "raw" data array, which we'll later use to build
-// the interface array.
-func (l *List) loadData(r io.Reader, count int) (err error) {
-	switch l.Contents {
-
-	case TypeEnd: // nothing to load
-		l.data = nil
-		return nil
-
-	case TypeByte:
-		raw := make([]Byte, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadByte(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeShort:
-		raw := make([]Short, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadShort(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeInt:
-		raw := make([]Int, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadInt(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeLong:
-		raw := make([]Long, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadLong(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeFloat:
-		raw := make([]Float, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadFloat(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeDouble:
-		raw := make([]Double, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadDouble(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeByteArray:
-		raw := make([]ByteArray, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadByteArray(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeString:
-		raw := make([]String, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadString(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeList:
-		raw := make([]List, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadList(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeCompound:
-		raw := make([]Compound, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadCompound(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeIntArray:
-		raw := make([]IntArray, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadIntArray(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	case TypeLongArray:
-		raw := make([]LongArray, count)
-		for i := 0; i < count; i++ {
-			raw[i], err = loadLongArray(r)
-			if err!= nil {
-				raw = raw[:i]
-				break
-			}
-		}
-		l.data = raw
-		return err
-
-	default:
-		return fmt.Errorf("unhandled tag type in List.loadData: %v", l.Contents)
-	}
+	return NewList(in)
 }
 
-// Iterate iterates over the list, passing each item in the list (as a Payload)
-// to the given function. If fn returns a non-nil error, Iterate stops and returns
-// the error.
-func (l List) Iterate(fn func(int, Tag) error) (err error) {
-	switch raw := l.data.(type) {
-
-	case []End:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-
-			err = fn(i, End{})
-			if err != nil {
-				break
-			}
-		}
-	case []Byte:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []Short:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []Int:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []Long:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []Float:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []Double:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []ByteArray:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []String:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []List:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []Compound:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []IntArray:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	case []LongArray:
-		count := len(raw)
-		for i := 0; i < count; i++ {
-err = fn(i, raw[i])
-
-			if err != nil {
-				break
-			}
-		}
-	default:
-		return fmt.Errorf("unhandled tag type in List.Iterate: %v", l.Contents)
-	}
-	return err
+// LongArray yields the LongArray stored under key in c, or an error if there
+// isn't one, or it's some other type.
+func (c Compound) LongArray(key String) (LongArray, error) {
+	return Get[LongArray](c, key)
 }
 
-// Length returns the length of the list, if applicable. Note, a list of End
-// is (I think) always of length 0, if it's even valid at all.
-func (l List) Length() int {
-	switch raw := l.data.(type) {
-
-	case []End:
-
-		return 0
-
-	case []Byte:
-
-		return len(raw)
-
-	case []Short:
-
-		return len(raw)
-
-	case []Int:
-
-		return len(raw)
-
-	case []Long:
-
-		return len(raw)
-
-	case []Float:
-
-		return len(raw)
-
-	case []Double:
-
-		return len(raw)
-
-	case []ByteArray:
-
-		return len(raw)
-
-	case []String:
-
-		return len(raw)
-
-	case []List:
-
-		return len(raw)
 
-	case []Compound:
-
-		return len(raw)
-
-	case []IntArray:
-
-		return len(raw)
 
-	case []LongArray:
-
-		return len(raw)
-
-	default:
-	 	return 0
-	}
-}
 
 // MakeList makes a list given a slice of any kind of payload object. Note,
 // not a slice of Tags, a slice of any of the specific concrete types
-// implement tag and aren't End.
+// implement tag and aren't End. NewList does the same thing with static
+// type checking.
 func MakeList(in interface{}) (l List, err error) {
-	switch in.(type) {
-
+	switch in := in.(type) {
 	case []End:
 		// We don't allow non-empty lists of Ends
-		l.Contents = TypeEnd
-		l.data = nil
-		return l, err
+		return List{Contents: TypeEnd}, nil
 	case []Byte:
-		l.Contents = TypeByte
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []Short:
-		l.Contents = TypeShort
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []Int:
-		l.Contents = TypeInt
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []Long:
-		l.Contents = TypeLong
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []Float:
-		l.Contents = TypeFloat
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []Double:
-		l.Contents = TypeDouble
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []ByteArray:
-		l.Contents = TypeByteArray
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []String:
-		l.Contents = TypeString
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []List:
-		l.Contents = TypeList
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []Compound:
-		l.Contents = TypeCompound
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []IntArray:
-		l.Contents = TypeIntArray
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	case []LongArray:
-		l.Contents = TypeLongArray
-		l.data = in
-		return l, err
+		return NewList(in), nil
 	default:
 		return l, fmt.Errorf("can't MakeList on %T", in)
 	}
 }
-
-// Element gives the ith element of l.
-func (l List)Element(i int) (out Tag, ok bool) {
-	switch data := l.data.(type) {
-
-	case []End:
-		return End{}, false
-	case []Byte:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []Short:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []Int:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []Long:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []Float:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []Double:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []ByteArray:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []String:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []List:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []Compound:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []IntArray:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	case []LongArray:
-		if i > 0 && i < len(data) {
-			return data[i], true
-		}
-		return nil, false
-	default:
-		return nil, false
-	}
-}
@@ -0,0 +1,212 @@
+package nbt
+
+import (
+	"errors"
+	"sort"
+)
+
+// Walking entire trees of tags.
+
+var (
+	// SkipSubtree, returned by a walk function, skips the children of the
+	// current node. In a post-order walk, it's the same as nil.
+	SkipSubtree = errors.New("skip this subtree")
+	// SkipAll, returned by a walk function, stops the walk, which then
+	// returns nil.
+	SkipAll = errors.New("skip everything")
+)
+
+// WalkFunc is called for each node visited by a walk, with the path to
+// that node from the root.
+type WalkFunc func(path Path, t Tag) error
+
+// MutateFunc is called for each node visited by WalkMutate. It returns
+// the tag which should replace t, which can just be t, or nil to delete
+// t from its parent. If it returns an error other than SkipSubtree or
+// SkipAll, the returned tag is ignored and t is left alone.
+type MutateFunc func(path Path, t Tag) (Tag, error)
+
+// WalkOptions controls the behavior of a walk. The zero value is a
+// pre-order walk which doesn't visit array elements.
+type WalkOptions struct {
+	// ArrayElements makes the walk visit the individual Byte, Int, or
+	// Long elements of arrays.
+	ArrayElements bool
+	// PostOrder visits each node's children before the node.
+	PostOrder bool
+}
+
+// Walk visits root and every node under it, in pre-order. Compound entries
+// are visited in sorted order of their keys, and list elements in order.
+func Walk(root Tag, fn WalkFunc) error {
+	return WalkOptions{}.Walk(root, fn)
+}
+
+// WalkPostOrder is like Walk, but visits children before their parents.
+func WalkPostOrder(root Tag, fn WalkFunc) error {
+	return WalkOptions{PostOrder: true}.Walk(root, fn)
+}
+
+// WalkMutate is like Walk, but lets fn replace or delete each node. In
+// a pre-order walk, the children of the replacement node are visited.
+// The updated root is returned; Compounds are modified in place, but
+// Lists and arrays are rebuilt.
+func WalkMutate(root Tag, fn MutateFunc) (Tag, error) {
+	return WalkOptions{}.WalkMutate(root, fn)
+}
+
+// sortedKeys yields the keys of c in sorted order.
+func sortedKeys(c Compound) []String {
+	keys := make([]String, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// walkElements indicates whether the elements of t should be visited.
+func (o WalkOptions) walkElements(t Tag) bool {
+	if t == nil {
+		return false
+	}
+	switch t.Type() {
+	case TypeList:
+		return true
+	case TypeByteArray, TypeIntArray, TypeLongArray:
+		return o.ArrayElements
+	}
+	return false
+}
+
+// Walk walks the tree under root using the given options.
+func (o WalkOptions) Walk(root Tag, fn WalkFunc) error {
+	err := o.walk(NewPath(root), root, fn)
+	if err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (o WalkOptions) walk(p Path, t Tag, fn WalkFunc) error {
+	if !o.PostOrder {
+		err := fn(p, t)
+		if err == SkipSubtree {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if c, ok := t.(Compound); ok {
+		for _, k := range sortedKeys(c) {
+			v, _ := c.entry(k)
+			if err := o.walk(p.child(k, v), v, fn); err != nil {
+				return err
+			}
+		}
+	} else if o.walkElements(t) {
+		for i := 0; i < TagLength(t); i++ {
+			elt, _ := TagElement(t, i)
+			if err := o.walk(p.child(Int(i), elt), elt, fn); err != nil {
+				return err
+			}
+		}
+	}
+	if o.PostOrder {
+		err := fn(p, t)
+		if err == SkipSubtree {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// WalkMutate walks the tree under root using the given options, letting
+// fn replace or delete nodes. See the WalkMutate function.
+func (o WalkOptions) WalkMutate(root Tag, fn MutateFunc) (Tag, error) {
+	out, err := o.mutate(NewPath(root), root, fn)
+	if err == SkipAll {
+		return out, nil
+	}
+	return out, err
+}
+
+func (o WalkOptions) mutate(p Path, t Tag, fn MutateFunc) (Tag, error) {
+	if !o.PostOrder {
+		n, err := fn(p, t)
+		switch err {
+		case nil:
+		case SkipSubtree:
+			return n, nil
+		case SkipAll:
+			return n, err
+		default:
+			return t, err
+		}
+		if n == nil {
+			return nil, nil
+		}
+		t = n
+		p.Tags[len(p.Tags)-1] = n
+	}
+	t, err := o.mutateChildren(p, t, fn)
+	if err != nil {
+		return t, err
+	}
+	if o.PostOrder {
+		n, err := fn(p, t)
+		switch err {
+		case nil, SkipSubtree:
+			return n, nil
+		case SkipAll:
+			return n, err
+		default:
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// mutateChildren applies fn to the children of t, yielding an updated t.
+// If fn fails, changes made so far are kept.
+func (o WalkOptions) mutateChildren(p Path, t Tag, fn MutateFunc) (Tag, error) {
+	if c, ok := t.(Compound); ok {
+		for _, k := range sortedKeys(c) {
+			v, _ := c.entry(k)
+			n, err := o.mutate(p.child(k, v), v, fn)
+			if n == nil {
+				delete(c, k)
+			} else {
+				c[k] = n
+			}
+			if err != nil {
+				return c, err
+			}
+		}
+		return c, nil
+	}
+	if !o.walkElements(t) {
+		return t, nil
+	}
+	elems := collectionElements(t)
+	updated := make([]Tag, 0, len(elems))
+	var err error
+	for i, e := range elems {
+		if err != nil {
+			updated = append(updated, e)
+			continue
+		}
+		var n Tag
+		n, err = o.mutate(p.child(Int(i), e), e, fn)
+		if n != nil {
+			updated = append(updated, n)
+		}
+	}
+	out, rebuildErr := rebuildCollection(t, updated)
+	if rebuildErr != nil {
+		return t, rebuildErr
+	}
+	return out, err
+}